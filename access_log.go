@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	mrand "math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogap/logs"
+)
+
+const (
+	REQUEST_ID_HEADER = "X-Request-Id"
+
+	DEFAULT_ACCESS_LOG_FILE          = "logs/access.log"
+	DEFAULT_ACCESS_LOG_MAX_SIZE      = 100
+	DEFAULT_ACCESS_LOG_MAX_BACKUPS   = 7
+	DEFAULT_ACCESS_LOG_BODY_MAX_SIZE = 4096
+)
+
+var (
+	AccessLogFields = []string{
+		"time",
+		"request_id",
+		"client_ip",
+		"method",
+		"path",
+		"apis",
+		"status",
+		"error_code",
+		"latency_ms",
+		"request_size",
+		"response_size",
+		"user_agent",
+		"request_body",
+		"response_body",
+	}
+)
+
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status int
+	size   int
+	body   *bytes.Buffer
+}
+
+func (p *responseRecorder) WriteHeader(code int) {
+	if p.status == 0 {
+		p.status = code
+	}
+	p.ResponseWriter.WriteHeader(code)
+}
+
+func (p *responseRecorder) Write(data []byte) (n int, err error) {
+	if p.status == 0 {
+		p.status = http.StatusOK
+	}
+
+	n, err = p.ResponseWriter.Write(data)
+	p.size += n
+
+	if p.body != nil {
+		p.body.Write(data[:n])
+	}
+	return
+}
+
+func (p *responseRecorder) Flush() {
+	if flusher, ok := p.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+type AccessLogger struct {
+	fields      []string
+	sampleRate  float64
+	bodyMaxSize int
+	writer      *rotateWriter
+}
+
+func NewAccessLogger(accessConf AccessLogConfig) (logger *AccessLogger, err error) {
+	fields := accessConf.Fields
+	if len(fields) == 0 {
+		fields = AccessLogFields
+	}
+
+	knownFields := map[string]bool{}
+	for _, field := range AccessLogFields {
+		knownFields[field] = true
+	}
+
+	for _, field := range fields {
+		if !knownFields[field] {
+			err = fmt.Errorf("unknown access log field: %s", field)
+			return
+		}
+	}
+
+	writer, e := newRotateWriter(accessConf.File, int64(accessConf.MaxSize)*1024*1024, accessConf.MaxBackups)
+	if e != nil {
+		err = e
+		return
+	}
+
+	logger = &AccessLogger{
+		fields:      fields,
+		sampleRate:  accessConf.BodySampleRate,
+		bodyMaxSize: accessConf.BodyMaxSize,
+		writer:      writer,
+	}
+
+	return
+}
+
+func (p *AccessLogger) sampleBody() bool {
+	return p.sampleRate > 0 && mrand.Float64() < p.sampleRate
+}
+
+func (p *AccessLogger) Log(r *http.Request, recorder *responseRecorder, requestBody []byte, sampled bool) {
+	ctx := apiRequestContext(r)
+	if ctx == nil {
+		return
+	}
+
+	apis := []string{}
+	errCodes := []string{}
+	for name, resp := range ctx.Responses {
+		apis = append(apis, multiCallAPIName(r, name))
+		if resp.Code != 0 {
+			errCodes = append(errCodes, fmt.Sprintf("%s:%d", resp.ErrorNamespace, resp.Code))
+		}
+	}
+	sort.Strings(apis)
+	sort.Strings(errCodes)
+
+	entry := map[string]interface{}{}
+	for _, field := range p.fields {
+		switch field {
+		case "time":
+			entry[field] = ctx.StartTime.Format(time.RFC3339Nano)
+		case "request_id":
+			entry[field] = ctx.RequestId
+		case "client_ip":
+			entry[field] = conf.HTTP.ClientIP(r).String()
+		case "method":
+			entry[field] = r.Method
+		case "path":
+			entry[field] = r.URL.Path
+		case "apis":
+			entry[field] = apis
+		case "status":
+			entry[field] = recorder.status
+		case "error_code":
+			entry[field] = strings.Join(errCodes, ",")
+		case "latency_ms":
+			entry[field] = float64(time.Now().Sub(ctx.StartTime)) / float64(time.Millisecond)
+		case "request_size":
+			entry[field] = len(requestBody)
+		case "response_size":
+			entry[field] = recorder.size
+		case "user_agent":
+			entry[field] = r.UserAgent()
+		case "request_body":
+			if sampled {
+				entry[field] = p.body(r, requestBody, false)
+			}
+		case "response_body":
+			if sampled && recorder.body != nil {
+				entry[field] = p.body(r, recorder.body.Bytes(), true)
+			}
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logs.Error(err)
+		return
+	}
+
+	if _, err = p.writer.Write(append(line, '\n')); err != nil {
+		logs.Error(err)
+	}
+}
+
+func (p *AccessLogger) body(r *http.Request, data []byte, isResponse bool) interface{} {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	apiName := requestAPIName(r)
+	isMulti := isMultiCall(r)
+
+	v, err := redactor.RedactJSON(apiName, data)
+	if err != nil {
+		return p.truncate(redactor.RedactText(apiName, string(data)))
+	}
+
+	content := v
+	resp, isMap := v.(map[string]interface{})
+	if isResponse && isMap {
+		content = resp["result"]
+		if !isMulti && content != nil {
+			resp["result"] = redactor.Redact(apiName, content)
+		}
+	}
+
+	if isMulti {
+		switch apis := content.(type) {
+		case map[string]interface{}:
+			for name, apiContent := range apis {
+				apis[name] = redactor.Redact(multiCallAPIName(r, name), apiContent)
+			}
+		case []interface{}:
+			for _, item := range apis {
+				if call, ok := item.(map[string]interface{}); ok {
+					api, _ := call["api"].(string)
+					call["params"] = redactor.Redact(strings.TrimSpace(api), call["params"])
+				}
+			}
+		}
+	}
+
+	if jsonData, e := json.Marshal(v); e == nil && len(jsonData) > p.bodyMaxSize {
+		return p.truncate(string(jsonData))
+	}
+
+	return v
+}
+
+func (p *AccessLogger) truncate(text string) string {
+	if len(text) > p.bodyMaxSize {
+		return text[:p.bodyMaxSize] + "..."
+	}
+	return text
+}
+
+func newRequestId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+func accessLogHandle(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, _ = withAPIRequestContext(r)
+
+		w.Header().Set(REQUEST_ID_HEADER, apiRequestContext(r).RequestId)
+
+		if accessLogger == nil {
+			handler(w, r)
+			return
+		}
+
+		var requestBody []byte
+		if r.Body != nil {
+			if body, e := ioutil.ReadAll(r.Body); e != nil {
+				logs.Error(e)
+			} else {
+				requestBody = body
+			}
+			r.Body.Close()
+			r.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+		}
+
+		sampled := accessLogger.sampleBody()
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		if sampled {
+			recorder.body = new(bytes.Buffer)
+		}
+
+		handler(recorder, r)
+
+		accessLogger.Log(r, recorder, requestBody, sampled)
+	}
+}
+
+type rotateWriter struct {
+	sync.Mutex
+
+	filename   string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func newRotateWriter(filename string, maxSize int64, maxBackups int) (writer *rotateWriter, err error) {
+	writer = &rotateWriter{
+		filename:   filename,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return
+	}
+
+	err = writer.open()
+	return
+}
+
+func (p *rotateWriter) open() (err error) {
+	var file *os.File
+	if file, err = os.OpenFile(p.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return
+	}
+
+	var fi os.FileInfo
+	if fi, err = file.Stat(); err != nil {
+		file.Close()
+		return
+	}
+
+	p.file = file
+	p.size = fi.Size()
+	return
+}
+
+func (p *rotateWriter) Write(data []byte) (n int, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.file == nil {
+		if err = p.open(); err != nil {
+			return
+		}
+	}
+
+	if p.maxSize > 0 && p.size+int64(len(data)) > p.maxSize && p.size > 0 {
+		if err = p.rotate(); err != nil {
+			logs.Error("rotate access log failed, keep writing to", p.filename, "error:", err)
+		}
+
+		if p.file == nil {
+			return
+		}
+	}
+
+	n, err = p.file.Write(data)
+	p.size += int64(n)
+	return
+}
+
+func (p *rotateWriter) rotate() (err error) {
+	p.file.Close()
+	p.file = nil
+
+	if p.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", p.filename, p.maxBackups))
+		for i := p.maxBackups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", p.filename, i), fmt.Sprintf("%s.%d", p.filename, i+1))
+		}
+		err = os.Rename(p.filename, p.filename+".1")
+	} else {
+		err = os.Truncate(p.filename, 0)
+	}
+
+	if e := p.open(); e != nil {
+		return e
+	}
+
+	return
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type apiContextKey struct{}
+
+type detachedContextKey struct{}
+
+type APIRequestContext struct {
+	StartTime time.Time
+	RequestId string
+	Responses map[string]APIResponse
+	Aliases   map[string]string
+	StreamId  string
+	Attempt   int
+
+	Rendered       string
+	RenderedStatus int
+
+	SubCall    bool
+	Content    interface{}
+	HasContent bool
+
+	PayloadSent  bool
+	CallbackWait uint64
+}
+
+func withAPIRequestContext(r *http.Request) (*http.Request, *APIRequestContext) {
+	if ctx := apiRequestContext(r); ctx != nil {
+		return r, ctx
+	}
+
+	ctx := &APIRequestContext{
+		StartTime: time.Now(),
+		RequestId: strings.TrimSpace(r.Header.Get(REQUEST_ID_HEADER)),
+	}
+
+	if ctx.RequestId == "" || len(ctx.RequestId) > 128 || strings.ContainsAny(ctx.RequestId, "\r\n\"") {
+		ctx.RequestId = newRequestId()
+	}
+
+	return r.WithContext(withAPIContextValue(r.Context(), ctx)), ctx
+}
+
+func withAPIContextValue(parent context.Context, ctx *APIRequestContext) context.Context {
+	return context.WithValue(parent, apiContextKey{}, ctx)
+}
+
+func detachRequest(r *http.Request, ctx *APIRequestContext) *http.Request {
+	return r.WithContext(context.WithValue(withAPIContextValue(context.Background(), ctx), detachedContextKey{}, true))
+}
+
+func isDetached(r *http.Request) bool {
+	detached, _ := r.Context().Value(detachedContextKey{}).(bool)
+	return detached
+}
+
+func isSubCall(r *http.Request) bool {
+	ctx := apiRequestContext(r)
+	return ctx != nil && ctx.SubCall
+}
+
+func apiRequestContext(r *http.Request) *APIRequestContext {
+	if ctx, ok := r.Context().Value(apiContextKey{}).(*APIRequestContext); ok {
+		return ctx
+	}
+	return nil
+}
+
+func requestId(r *http.Request) string {
+	if ctx := apiRequestContext(r); ctx != nil {
+		return ctx.RequestId
+	}
+	return r.Header.Get(REQUEST_ID_HEADER)
+}
+
+func requestAPIName(r *http.Request) (apiName string) {
+	apiName = strings.TrimSpace(r.Header.Get(conf.HTTP.APIHeader))
+	if apiName == "" && conf.HTTP.PATH != r.URL.Path {
+		apiName = strings.TrimPrefix(r.URL.Path, conf.HTTP.PATH+"/")
+	}
+	return
+}
+
+func finishAPIRequest(r *http.Request, responses map[string]APIResponse) {
+	if ctx := apiRequestContext(r); ctx != nil {
+		ctx.Responses = responses
+	}
+
+	recordAPIStats(r, responses)
+}
@@ -21,19 +21,84 @@ xdomain.masters({{#masters#}});
 </html>`
 }
 
-func renderXDomainProxy(masters []string, path string) string {
-	m := map[string]string{}
-	for _, master := range masters {
-		m["http://"+master] = path
-	}
+func renderXDomainProxy(masters map[string]string) string {
+	jsonData, _ := json.MarshalIndent(masters, "", "  ")
 
-	for _, master := range masters {
-		m["https://"+master] = path
-	}
+	return strings.Replace(xdomainProxy(), "{{#masters#}}", string(jsonData), -1)
+}
 
-	jsonData, _ := json.MarshalIndent(m, "", "  ")
+func xdomainBridge() string {
+	return `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<title></title>
+<script>
+(function () {
+    var origins = {{#origins#}};
+    var endpoint = {{#endpoint#}};
+    var exposeHeaders = {{#expose#}};
 
-	return strings.Replace(xdomainProxy(), "{{#masters#}}", string(jsonData), -1)
+    var allowed = function (origin) {
+        for (var i = 0; i < origins.length; i++) {
+            if (new RegExp(origins[i]).test(origin)) {
+                return true;
+            }
+        }
+        return false;
+    };
+
+    window.addEventListener("message", function (event) {
+        var req = event.data;
+        if (!req || typeof req !== "object" || req.type !== "inlet_http_api.request" || !allowed(event.origin)) {
+            return;
+        }
+
+        var xhr = new XMLHttpRequest();
+        xhr.open("POST", endpoint, true);
+
+        var headers = req.headers || {};
+        for (var name in headers) {
+            xhr.setRequestHeader(name, headers[name]);
+        }
+        if (!headers["Content-Type"]) {
+            xhr.setRequestHeader("Content-Type", "application/json");
+        }
+
+        xhr.onreadystatechange = function () {
+            if (xhr.readyState !== 4) {
+                return;
+            }
+
+            var respHeaders = {};
+            for (var i = 0; i < exposeHeaders.length; i++) {
+                var value = xhr.getResponseHeader(exposeHeaders[i]);
+                if (value !== null) {
+                    respHeaders[exposeHeaders[i]] = value;
+                }
+            }
+
+            event.source.postMessage({
+                type: "inlet_http_api.response",
+                id: req.id,
+                status: xhr.status,
+                headers: respHeaders,
+                body: xhr.responseText
+            }, event.origin);
+        };
+
+        xhr.send(typeof req.body === "string" ? req.body : JSON.stringify(req.body || {}));
+    }, false);
+
+    if (window.parent !== window) {
+        window.parent.postMessage({type: "inlet_http_api.ready"}, "*");
+    }
+})();
+</script>
+</head>
+<body>
+</body>
+</html>`
 }
 
 func xdomainProxyJS() string {
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gogap/errors"
+	"github.com/gogap/logs"
+)
+
+const (
+	DEFAULT_ASYNC_PATH    = "/jobs"
+	DEFAULT_ASYNC_DIR     = "jobs"
+	DEFAULT_ASYNC_TTL     = 3600
+	DEFAULT_ASYNC_TIMEOUT = 600000
+
+	ASYNC_EXPIRE_INTERVAL = time.Minute
+)
+
+type AsyncJobStatus struct {
+	JobId     string    `json:"job_id"`
+	API       string    `json:"api"`
+	Status    string    `json:"status"`
+	StatusURL string    `json:"status_url"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func isAsyncCall(r *http.Request) bool {
+	return !isMultiCall(r) && asyncAPI[requestAPIName(r)]
+}
+
+func asyncJobStatusURL(jobId string) string {
+	return conf.HTTP.PATH + conf.HTTP.Async.Path + "/" + jobId
+}
+
+func newAsyncJobStatus(job *AsyncJob) AsyncJobStatus {
+	return AsyncJobStatus{
+		JobId:     job.Id,
+		API:       job.API,
+		Status:    job.Status,
+		StatusURL: asyncJobStatusURL(job.Id),
+		CreatedAt: job.CreatedAt,
+		ExpiresAt: job.ExpiresAt,
+	}
+}
+
+func asyncCallHandle(w http.ResponseWriter, r *http.Request) {
+	apiName := requestAPIName(r)
+
+	if _, err := apiGraphProvider.Resolve(r, apiName); err != nil {
+		errorResponseHandler(err, w, r)
+		return
+	}
+
+	callback := requestCallbackURL(r)
+	if callback != "" && !webhookAllowlist.Allowed(apiName, callback) {
+		errorResponseHandler(ERR_WEBHOOK_NOT_ALLOWED.New(errors.Params{"api": apiName, "url": callback}), w, r)
+		return
+	}
+
+	body, e := readRequestBody(r)
+	if e != nil {
+		errorResponseHandler(e, w, r)
+		return
+	}
+
+	now := time.Now()
+	ttl := time.Duration(conf.HTTP.Async.TTL) * time.Second
+
+	job := &AsyncJob{
+		Id:        newRequestId(),
+		API:       apiName,
+		RequestId: requestId(r),
+		Callback:  callback,
+		Status:    JOB_STATUS_PENDING,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if e := jobStore.Put(job); e != nil {
+		errorResponseHandler(ERR_ASYNC_JOB_STORE_FAILED.New(errors.Params{"err": e}), w, r)
+		return
+	}
+
+	requested := parseTimeoutHeader(r, API_CALL_TIMEOUT)
+	if requested <= 0 {
+		requested = time.Duration(conf.HTTP.Async.Timeout) * time.Millisecond
+	}
+
+	timeout := effectiveAsyncTimeout(apiName, requested)
+	writeEffectiveTimeout(w, timeout)
+
+	detached := detachRequest(r, apiRequestContext(r))
+	done := drainTracker.Track(apiName)
+	go func() {
+		defer done()
+		runAsyncJob(detached, job, body, timeout)
+	}()
+
+	resp := APIResponse{Result: newAsyncJobStatus(job)}
+
+	if ctx := apiRequestContext(r); ctx != nil {
+		ctx.Responses = map[string]APIResponse{apiName: resp}
+	}
+
+	w.Header().Set("Location", asyncJobStatusURL(job.Id))
+	writeResponseWithStatusCode(resp, w, r, http.StatusAccepted)
+}
+
+func runAsyncJob(r *http.Request, job *AsyncJob, body []byte, timeout time.Duration) {
+	resp := apiDispatcher.Call(r, APICall{
+		Name:    job.API,
+		API:     job.API,
+		Body:    body,
+		Timeout: timeout,
+	})
+
+	finished := *job
+	finished.Status = JOB_STATUS_DONE
+	finished.FinishedAt = time.Now()
+	finished.ExpiresAt = finished.FinishedAt.Add(time.Duration(conf.HTTP.Async.TTL) * time.Second)
+	finished.Response = &resp
+
+	if e := jobStore.Put(&finished); e != nil {
+		logs.Error("store async job failed, job:", job.Id, "api:", job.API, "error:", e)
+	}
+
+	if finished.Callback != "" {
+		webhookDeliverer.Deliver(&finished)
+	}
+}
+
+func asyncJobHandle(w http.ResponseWriter, r *http.Request) {
+	jobId := strings.TrimPrefix(r.URL.Path, conf.HTTP.PATH+conf.HTTP.Async.Path+"/")
+
+	job, e := jobStore.Get(jobId)
+	if e != nil {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_ASYNC_JOB_STORE_FAILED.New(errors.Params{"err": e})), w, r, http.StatusInternalServerError)
+		return
+	}
+
+	if job == nil || job.Expired(time.Now()) {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_ASYNC_JOB_NOT_FOUND.New(errors.Params{"job": jobId})), w, r, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+
+	if job.Status != JOB_STATUS_DONE || job.Response == nil {
+		writeResponseWithStatusCode(APIResponse{Result: newAsyncJobStatus(job)}, w, r, http.StatusAccepted)
+		return
+	}
+
+	opts := RenderOptions{Fields: requestFieldSelection(r)}
+	if text, e := responseRenderer.RenderWithOptions(false, map[string]APIResponse{job.API: *job.Response}, opts); e != nil {
+		err := ERR_API_RESPONSE_REDNER_FAILED.New(errors.Params{"err": e})
+		writeResponse(newErrorAPIResponse(err), w, r)
+	} else {
+		writeTextResponse(text, w, r)
+	}
+}
+
+func expireAsyncJobs() {
+	ticker := time.NewTicker(ASYNC_EXPIRE_INTERVAL)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if e := jobStore.Expire(now); e != nil {
+			logs.Warn("expire async jobs failed, error:", e)
+		}
+	}
+}
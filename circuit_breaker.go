@@ -0,0 +1,253 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogap/logs"
+)
+
+const (
+	DEFAULT_CIRCUIT_WINDOW        = 30
+	DEFAULT_CIRCUIT_MIN_REQUESTS  = 20
+	DEFAULT_CIRCUIT_FAILURE_RATIO = 0.5
+	DEFAULT_CIRCUIT_OPEN_TIMEOUT  = 30
+	DEFAULT_CIRCUIT_PROBES        = 1
+)
+
+const (
+	CIRCUIT_CLOSED = iota
+	CIRCUIT_OPEN
+	CIRCUIT_HALF_OPEN
+)
+
+var circuitStateNames = map[int]string{
+	CIRCUIT_CLOSED:    "closed",
+	CIRCUIT_OPEN:      "open",
+	CIRCUIT_HALF_OPEN: "half-open",
+}
+
+type circuitBucket struct {
+	second   int64
+	total    int
+	failures int
+}
+
+type circuitBreaker struct {
+	sync.Mutex
+
+	name string
+	conf CircuitBreakerConfig
+
+	state    int
+	openedAt time.Time
+	probes   int
+	probedAt time.Time
+	buckets  []circuitBucket
+}
+
+func newCircuitBreaker(name string, breakerConf CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		name:    name,
+		conf:    breakerConf,
+		buckets: make([]circuitBucket, breakerConf.Window),
+	}
+}
+
+func (p *circuitBreaker) setState(state int, now time.Time) {
+	if p.state == state {
+		return
+	}
+
+	logs.Warn("circuit of address", p.name, "changed from", circuitStateNames[p.state], "to", circuitStateNames[state])
+
+	p.state = state
+	p.probes = 0
+
+	switch state {
+	case CIRCUIT_OPEN:
+		p.openedAt = now
+	case CIRCUIT_CLOSED:
+		p.buckets = make([]circuitBucket, p.conf.Window)
+	}
+}
+
+func (p *circuitBreaker) Allow(now time.Time) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.permits(now) {
+		return false
+	}
+
+	p.take(now)
+
+	return true
+}
+
+// permits and take are called with the lock held, permits never uses up a probe
+func (p *circuitBreaker) permits(now time.Time) bool {
+	openTimeout := time.Duration(p.conf.OpenTimeout) * time.Second
+
+	switch p.state {
+	case CIRCUIT_OPEN:
+		return now.Sub(p.openedAt) >= openTimeout
+	case CIRCUIT_HALF_OPEN:
+		return p.probes < p.conf.Probes || now.Sub(p.probedAt) >= openTimeout
+	}
+
+	return true
+}
+
+func (p *circuitBreaker) take(now time.Time) {
+	switch p.state {
+	case CIRCUIT_CLOSED:
+		return
+	case CIRCUIT_OPEN:
+		p.setState(CIRCUIT_HALF_OPEN, now)
+	}
+
+	p.probes++
+	p.probedAt = now
+}
+
+func (p *circuitBreaker) Record(now time.Time, failed bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	switch p.state {
+	case CIRCUIT_HALF_OPEN:
+		if failed {
+			p.setState(CIRCUIT_OPEN, now)
+		} else {
+			p.setState(CIRCUIT_CLOSED, now)
+		}
+		return
+	case CIRCUIT_OPEN:
+		return
+	}
+
+	second := now.Unix()
+	bucket := &p.buckets[second%int64(len(p.buckets))]
+	if bucket.second != second {
+		*bucket = circuitBucket{second: second}
+	}
+
+	bucket.total++
+	if failed {
+		bucket.failures++
+	}
+
+	total, failures := 0, 0
+	for _, b := range p.buckets {
+		if second-b.second < int64(len(p.buckets)) {
+			total += b.total
+			failures += b.failures
+		}
+	}
+
+	if total >= p.conf.MinRequests && float64(failures)/float64(total) >= p.conf.FailureRatio {
+		p.setState(CIRCUIT_OPEN, now)
+	}
+}
+
+type CircuitBreakers struct {
+	breakers map[string]*circuitBreaker
+	apis     map[string][]string
+}
+
+func NewCircuitBreakers(breakerConf CircuitBreakerConfig, addressConf []AddressConfig, graphConf []GraphsConfig, hooks GraphHooks) *CircuitBreakers {
+	breakers := &CircuitBreakers{
+		breakers: make(map[string]*circuitBreaker),
+		apis:     make(map[string][]string),
+	}
+
+	for _, addr := range addressConf {
+		name := strings.TrimSpace(addr.Name)
+		breakers.breakers[name] = newCircuitBreaker(name, breakerConf)
+	}
+
+	// a failure is only charged to the addresses of a single graph, hook
+	// addresses and addresses used by several graphs could not tell whose it is
+	graphs := map[string]int{}
+	for _, name := range append(append([]string{}, hooks.Before...), hooks.After...) {
+		graphs[name] += 2
+	}
+
+	for _, graph := range graphConf {
+		seen := map[string]bool{}
+		for _, name := range graph.Graph {
+			if !seen[name] {
+				seen[name] = true
+				graphs[name]++
+			}
+		}
+	}
+
+	for _, graph := range graphConf {
+		seen := map[string]bool{}
+		for _, name := range graph.Graph {
+			if _, exist := breakers.breakers[name]; exist && !seen[name] && graphs[name] == 1 {
+				seen[name] = true
+				breakers.apis[graph.API] = append(breakers.apis[graph.API], name)
+			}
+		}
+		// a stable order keeps Allow from locking breakers against each other
+		sort.Strings(breakers.apis[graph.API])
+	}
+
+	return breakers
+}
+
+func (p *CircuitBreakers) Allow(apiName string) (openAddress string, allowed bool) {
+	if p == nil {
+		return "", true
+	}
+
+	names := p.apis[apiName]
+	for _, name := range names {
+		p.breakers[name].Lock()
+		defer p.breakers[name].Unlock()
+	}
+
+	now := time.Now()
+	for _, name := range names {
+		if !p.breakers[name].permits(now) {
+			return name, false
+		}
+	}
+
+	for _, name := range names {
+		p.breakers[name].take(now)
+	}
+
+	return "", true
+}
+
+func (p *CircuitBreakers) States() map[string]string {
+	if p == nil {
+		return nil
+	}
+
+	states := make(map[string]string)
+	for name, breaker := range p.breakers {
+		breaker.Lock()
+		states[name] = circuitStateNames[breaker.state]
+		breaker.Unlock()
+	}
+
+	return states
+}
+
+func (p *CircuitBreakers) Record(apiName string, failed bool) {
+	if p == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, name := range p.apis[apiName] {
+		p.breakers[name].Record(now, failed)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	breakerConf := CircuitBreakerConfig{Window: 10, MinRequests: 4, FailureRatio: 0.5, OpenTimeout: 30, Probes: 1}
+	start := time.Unix(1000000, 0)
+
+	type step struct {
+		at     time.Duration
+		allow  bool
+		record string
+		state  int
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "stays closed under min requests",
+			steps: []step{
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+			},
+		},
+		{
+			name: "opens at failure ratio",
+			steps: []step{
+				{at: 0, allow: true, record: "ok", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "ok", state: CIRCUIT_CLOSED},
+				{at: time.Second, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: time.Second, allow: true, record: "fail", state: CIRCUIT_OPEN},
+				{at: 29 * time.Second, allow: false, state: CIRCUIT_OPEN},
+			},
+		},
+		{
+			name: "failures outside the window are forgotten",
+			steps: []step{
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 20 * time.Second, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+			},
+		},
+		{
+			name: "half open probe closes",
+			steps: []step{
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_OPEN},
+				{at: 30 * time.Second, allow: true, state: CIRCUIT_HALF_OPEN},
+				{at: 31 * time.Second, allow: false, state: CIRCUIT_HALF_OPEN},
+				{at: 31 * time.Second, record: "ok", state: CIRCUIT_CLOSED},
+				{at: 31 * time.Second, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+			},
+		},
+		{
+			name: "half open probe reopens",
+			steps: []step{
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_OPEN},
+				{at: 30 * time.Second, allow: true, record: "fail", state: CIRCUIT_OPEN},
+				{at: 59 * time.Second, allow: false, state: CIRCUIT_OPEN},
+				{at: 60 * time.Second, allow: true, state: CIRCUIT_HALF_OPEN},
+			},
+		},
+		{
+			name: "lost probe is retried after open timeout",
+			steps: []step{
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_CLOSED},
+				{at: 0, allow: true, record: "fail", state: CIRCUIT_OPEN},
+				{at: 30 * time.Second, allow: true, state: CIRCUIT_HALF_OPEN},
+				{at: 59 * time.Second, allow: false, state: CIRCUIT_HALF_OPEN},
+				{at: 60 * time.Second, allow: true, state: CIRCUIT_HALF_OPEN},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		breaker := newCircuitBreaker("address", breakerConf)
+
+		for i, s := range test.steps {
+			now := start.Add(s.at)
+
+			if s.record == "" || s.allow {
+				if allow := breaker.Allow(now); allow != s.allow {
+					t.Errorf("%s: step %d Allow = %v, want %v", test.name, i, allow, s.allow)
+				}
+			}
+
+			if s.record != "" {
+				breaker.Record(now, s.record == "fail")
+			}
+
+			if breaker.state != s.state {
+				t.Errorf("%s: step %d state = %s, want %s", test.name, i, circuitStateNames[breaker.state], circuitStateNames[s.state])
+			}
+		}
+	}
+}
+
+func TestNewCircuitBreakersAddresses(t *testing.T) {
+	addressConf := []AddressConfig{{Name: "auth"}, {Name: "user"}, {Name: "profile"}, {Name: "task"}, {Name: "callback"}, {Name: "audit"}}
+	graphConf := []GraphsConfig{
+		{API: "api.user.get", Graph: []string{"user", "user", "profile"}},
+		{API: "api.task.new", Graph: []string{"task", "callback"}},
+		{API: "api.task.list", Graph: []string{"user", "unknown", "callback"}},
+		{API: "api.audit.get", Graph: []string{"auth", "audit"}},
+	}
+	hooks := GraphHooks{Before: []string{"auth"}, After: []string{"audit"}}
+
+	breakers := NewCircuitBreakers(CircuitBreakerConfig{Window: 10}, addressConf, graphConf, hooks)
+
+	expected := map[string][]string{
+		"api.user.get": {"profile"},
+		"api.task.new": {"task"},
+	}
+
+	if !reflect.DeepEqual(breakers.apis, expected) {
+		t.Errorf("addresses of apis = %v, want %v", breakers.apis, expected)
+	}
+}
+
+func TestCircuitBreakersAllowKeepsProbes(t *testing.T) {
+	breakerConf := CircuitBreakerConfig{Window: 10, MinRequests: 1, FailureRatio: 0.5, OpenTimeout: 30, Probes: 1}
+	now := time.Now()
+
+	breakers := &CircuitBreakers{
+		breakers: map[string]*circuitBreaker{
+			"a": newCircuitBreaker("a", breakerConf),
+			"b": newCircuitBreaker("b", breakerConf),
+		},
+		apis: map[string][]string{"api": {"a", "b"}},
+	}
+
+	breakers.breakers["a"].Record(now.Add(-time.Minute), true)
+	breakers.breakers["b"].Record(now, true)
+
+	if name, allowed := breakers.Allow("api"); allowed || name != "b" {
+		t.Errorf("Allow = %s, %v, want b, false", name, allowed)
+	}
+
+	if a := breakers.breakers["a"]; a.probes != 0 {
+		t.Errorf("probes of a = %d after a rejected call, want 0", a.probes)
+	}
+
+	breakers.breakers["b"].openedAt = now.Add(-time.Minute)
+
+	if name, allowed := breakers.Allow("api"); !allowed {
+		t.Errorf("Allow = %s, %v, want allowed", name, allowed)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if breaker := breakers.breakers[name]; breaker.state != CIRCUIT_HALF_OPEN || breaker.probes != 1 {
+			t.Errorf("breaker %s = %s with %d probes, want half-open with 1", name, circuitStateNames[breaker.state], breaker.probes)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+func parseCIDRs(cidrs []string) (nets []*net.IPNet, err error) {
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip == nil {
+				err = fmt.Errorf("bad ip address: %s", cidr)
+				return
+			} else if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		var ipNet *net.IPNet
+		if _, ipNet, err = net.ParseCIDR(cidr); err != nil {
+			return
+		}
+
+		nets = append(nets, ipNet)
+	}
+	return
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(strings.TrimSpace(host))
+}
+
+func (p *HTTPConfig) isTrustedProxy(ip net.IP) bool {
+	return containsIP(p.trustedProxies, ip)
+}
+
+type ClientInfo struct {
+	IP     net.IP
+	Scheme string
+	Host   string
+}
+
+type forwardedHop struct {
+	IP     net.IP
+	Scheme string
+	Host   string
+}
+
+func parseForwardedFor(value string) net.IP {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+
+	if strings.HasPrefix(value, "[") {
+		if end := strings.Index(value, "]"); end > 0 {
+			return net.ParseIP(value[1:end])
+		}
+		return nil
+	}
+
+	if ip := net.ParseIP(value); ip != nil {
+		return ip
+	}
+
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		return net.ParseIP(host)
+	}
+
+	return nil
+}
+
+func parseForwarded(headers []string) (hops []forwardedHop) {
+	for _, header := range headers {
+		for _, element := range strings.Split(header, ",") {
+			hop := forwardedHop{}
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+
+				value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+				switch strings.ToLower(strings.TrimSpace(kv[0])) {
+				case "for":
+					hop.IP = parseForwardedFor(value)
+				case "proto":
+					hop.Scheme = strings.ToLower(value)
+				case "host":
+					hop.Host = value
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return
+}
+
+func splitHeaderValues(headers []string) (values []string) {
+	for _, header := range headers {
+		for _, value := range strings.Split(header, ",") {
+			values = append(values, strings.TrimSpace(value))
+		}
+	}
+	return
+}
+
+func (p *HTTPConfig) forwardedHops(r *http.Request) (hops []forwardedHop) {
+	if forwarded := r.Header["Forwarded"]; len(forwarded) > 0 {
+		return parseForwarded(forwarded)
+	}
+
+	forwardedFor := splitHeaderValues(r.Header["X-Forwarded-For"])
+	if len(forwardedFor) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-Ip")); realIP != "" {
+			forwardedFor = []string{realIP}
+		}
+	}
+
+	protos := splitHeaderValues(r.Header["X-Forwarded-Proto"])
+	hosts := splitHeaderValues(r.Header["X-Forwarded-Host"])
+
+	// on a hop-count mismatch only the nearest proxy's value is reliable
+	valueAt := func(values []string, i int) string {
+		if len(values) == len(forwardedFor) {
+			return values[i]
+		} else if len(values) > 0 && i == len(forwardedFor)-1 {
+			return values[len(values)-1]
+		}
+		return ""
+	}
+
+	for i, value := range forwardedFor {
+		hops = append(hops, forwardedHop{
+			IP:     parseForwardedFor(value),
+			Scheme: strings.ToLower(valueAt(protos, i)),
+			Host:   valueAt(hosts, i),
+		})
+	}
+
+	return
+}
+
+func (p *HTTPConfig) ClientInfo(r *http.Request) ClientInfo {
+	info := ClientInfo{
+		IP:     remoteIP(r),
+		Scheme: "http",
+		Host:   r.Host,
+	}
+
+	if r.TLS != nil {
+		info.Scheme = "https"
+	}
+
+	if !p.isTrustedProxy(info.IP) && !fromTerminator(r) {
+		return info
+	}
+
+	hops := p.forwardedHops(r)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := hops[i]
+		if hop.IP == nil {
+			break
+		}
+
+		info.IP = hop.IP
+		if hop.Scheme == "http" || hop.Scheme == "https" {
+			info.Scheme = hop.Scheme
+		}
+		if hop.Host != "" {
+			info.Host = hop.Host
+		}
+
+		if !p.isTrustedProxy(hop.IP) {
+			break
+		}
+	}
+
+	return info
+}
+
+func (p *HTTPConfig) ClientIP(r *http.Request) net.IP {
+	return p.ClientInfo(r).IP
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	tests := []struct {
+		cidrs []string
+		nets  []string
+		bad   bool
+	}{
+		{cidrs: nil, nets: nil},
+		{cidrs: []string{"", "  "}, nets: nil},
+		{cidrs: []string{"10.0.0.0/8"}, nets: []string{"10.0.0.0/8"}},
+		{cidrs: []string{" 10.1.2.3/8 "}, nets: []string{"10.0.0.0/8"}},
+		{cidrs: []string{"192.168.1.1"}, nets: []string{"192.168.1.1/32"}},
+		{cidrs: []string{"::1"}, nets: []string{"::1/128"}},
+		{cidrs: []string{"fd00::/8", "127.0.0.1"}, nets: []string{"fd00::/8", "127.0.0.1/32"}},
+
+		{cidrs: []string{"10.0.0.0/33"}, bad: true},
+		{cidrs: []string{"10.0.0"}, bad: true},
+		{cidrs: []string{"example.com"}, bad: true},
+		{cidrs: []string{"10.0.0.0/8", "bad"}, bad: true},
+	}
+
+	for _, test := range tests {
+		nets, err := parseCIDRs(test.cidrs)
+		if test.bad {
+			if err == nil {
+				t.Errorf("parseCIDRs(%q) = %v, want error", test.cidrs, nets)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseCIDRs(%q) failed: %s", test.cidrs, err)
+			continue
+		}
+
+		if len(nets) != len(test.nets) {
+			t.Errorf("parseCIDRs(%q) = %v, want %v", test.cidrs, nets, test.nets)
+			continue
+		}
+
+		for i := range nets {
+			if nets[i].String() != test.nets[i] {
+				t.Errorf("parseCIDRs(%q) = %v, want %v", test.cidrs, nets, test.nets)
+				break
+			}
+		}
+	}
+}
+
+func TestClientInfo(t *testing.T) {
+	trusted, err := parseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpConf := &HTTPConfig{trustedProxies: trusted}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		ip      string
+		scheme  string
+		host    string
+	}{
+		{name: "direct", remote: "1.2.3.4:5678", ip: "1.2.3.4", scheme: "http", host: "api.local"},
+		{name: "untrusted forwarded", remote: "1.2.3.4:5678", headers: map[string][]string{"X-Forwarded-For": {"9.9.9.9"}}, ip: "1.2.3.4", scheme: "http", host: "api.local"},
+		{name: "trusted xff", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"9.9.9.9"}}, ip: "9.9.9.9", scheme: "http", host: "api.local"},
+		{name: "xff chain", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"6.6.6.6, 9.9.9.9, 10.0.0.2"}}, ip: "9.9.9.9", scheme: "http", host: "api.local"},
+		{name: "xff split headers", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"6.6.6.6", "9.9.9.9"}}, ip: "9.9.9.9", scheme: "http", host: "api.local"},
+		{name: "xff garbage stops", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"9.9.9.9, garbage"}}, ip: "10.0.0.1", scheme: "http", host: "api.local"},
+		{name: "xff with port", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"9.9.9.9:1234"}}, ip: "9.9.9.9", scheme: "http", host: "api.local"},
+		{name: "real ip", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Real-Ip": {"9.9.9.9"}}, ip: "9.9.9.9", scheme: "http", host: "api.local"},
+		{name: "proto and host", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"9.9.9.9"}, "X-Forwarded-Proto": {"HTTPS"}, "X-Forwarded-Host": {"example.com"}}, ip: "9.9.9.9", scheme: "https", host: "example.com"},
+		{name: "bad proto", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"9.9.9.9"}, "X-Forwarded-Proto": {"gopher"}}, ip: "9.9.9.9", scheme: "http", host: "api.local"},
+		{name: "hop mismatch uses last value", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"9.9.9.9, 10.0.0.2"}, "X-Forwarded-Proto": {"http, https, https"}, "X-Forwarded-Host": {"evil.com, example.com, example.com"}}, ip: "9.9.9.9", scheme: "https", host: "example.com"},
+		{name: "hop mismatch ignores spoofed first value", remote: "10.0.0.1:5678", headers: map[string][]string{"X-Forwarded-For": {"9.9.9.9"}, "X-Forwarded-Host": {"evil.com, example.com"}}, ip: "9.9.9.9", scheme: "http", host: "example.com"},
+		{name: "forwarded", remote: "10.0.0.1:5678", headers: map[string][]string{"Forwarded": {`for=9.9.9.9;proto=https;host=example.com`}}, ip: "9.9.9.9", scheme: "https", host: "example.com"},
+		{name: "forwarded ipv6", remote: "10.0.0.1:5678", headers: map[string][]string{"Forwarded": {`for="[2001:db8::1]:4711"`}}, ip: "2001:db8::1", scheme: "http", host: "api.local"},
+		{name: "forwarded over xff", remote: "10.0.0.1:5678", headers: map[string][]string{"Forwarded": {"for=9.9.9.9"}, "X-Forwarded-For": {"8.8.8.8"}}, ip: "9.9.9.9", scheme: "http", host: "api.local"},
+		{name: "forwarded chain", remote: "10.0.0.1:5678", headers: map[string][]string{"Forwarded": {"for=6.6.6.6, for=9.9.9.9;proto=https, for=10.0.0.2"}}, ip: "9.9.9.9", scheme: "https", host: "api.local"},
+		{name: "forwarded unknown stops", remote: "10.0.0.1:5678", headers: map[string][]string{"Forwarded": {"for=unknown"}}, ip: "10.0.0.1", scheme: "http", host: "api.local"},
+	}
+
+	for _, test := range tests {
+		r := &http.Request{RemoteAddr: test.remote, Host: "api.local", Header: http.Header{}}
+		for key, values := range test.headers {
+			r.Header[key] = values
+		}
+
+		info := httpConf.ClientInfo(r)
+		if !info.IP.Equal(net.ParseIP(test.ip)) || info.Scheme != test.scheme || info.Host != test.host {
+			t.Errorf("%s: ClientInfo = %s %s %s, want %s %s %s", test.name, info.IP, info.Scheme, info.Host, test.ip, test.scheme, test.host)
+		}
+	}
+}
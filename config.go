@@ -7,7 +7,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
-	"net/url"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -26,6 +26,7 @@ type InletHTTPAPIConfig struct {
 	Address            []AddressConfig `json:"address"`
 	Graphs             []GraphsConfig  `json:"graphs"`
 	GraphHooks         GraphHooks      `json:"graph_hooks"`
+	Redact             RedactConfig    `json:"redact"`
 }
 
 type GraphHooks struct {
@@ -42,84 +43,218 @@ type SignatureConfig struct {
 }
 
 type HTTPConfig struct {
-	Address            string            `json:"address"`
-	Server             string            `json:"server"`
-	APIHeader          string            `json:"api_header"`
-	CookiesDomain      string            `json:"cookies_domain"`
-	EnableStat         bool              `json:"enable_stat"`
-	P3P                string            `json:"p3p"`
-	AllowOrigins       []string          `json:"allow_origins"`
-	AllowHeaders       []string          `json:"allow_headers"`
-	PATH               string            `json:"path"`
-	ResponseHeaders    map[string]string `json:"response_headers"`
-	PassThroughHeaders []string          `json:"pass_through_headers"`
-	Signature          SignatureConfig   `json:"signature"`
-
-	_AllowHeaders string          `json:"-"`
-	allowOrigins  map[string]bool `json:"-"`
+	Address            string               `json:"address"`
+	Server             string               `json:"server"`
+	Timeout            int64                `json:"timeout"`
+	APIHeader          string               `json:"api_header"`
+	CookiesDomain      string               `json:"cookies_domain"`
+	EnableStat         bool                 `json:"enable_stat"`
+	P3P                string               `json:"p3p"`
+	AllowOrigins       []string             `json:"allow_origins"`
+	AllowHeaders       []string             `json:"allow_headers"`
+	PATH               string               `json:"path"`
+	ResponseHeaders    map[string]string    `json:"response_headers"`
+	PassThroughHeaders []string             `json:"pass_through_headers"`
+	Signature          SignatureConfig      `json:"signature"`
+	Stats              StatsConfig          `json:"stats"`
+	AccessLog          AccessLogConfig      `json:"access_log"`
+	TrustedProxies     []string             `json:"trusted_proxies"`
+	IPFilter           IPFilterConfig       `json:"ip_filter"`
+	CORS               CORSConfig           `json:"cors"`
+	XDomain            XDomainConfig        `json:"xdomain"`
+	MultiCall          MultiCallConfig      `json:"multi_call"`
+	JSONRPC            JSONRPCConfig        `json:"jsonrpc"`
+	Async              AsyncConfig          `json:"async"`
+	Webhook            WebhookConfig        `json:"webhook"`
+	Stream             StreamConfig         `json:"stream"`
+	WebSocket          WebSocketConfig      `json:"websocket"`
+	Idempotency        IdempotencyConfig    `json:"idempotency"`
+	CircuitBreaker     CircuitBreakerConfig `json:"circuit_breaker"`
+	LoadShedding       LoadSheddingConfig   `json:"load_shedding"`
+	Shutdown           ShutdownConfig       `json:"shutdown"`
+	Health             HealthConfig         `json:"health"`
+	TLS                TLSConfig            `json:"tls"`
+
+	trustedProxies []*net.IPNet `json:"-"`
 }
 
-type RendererConfig struct {
-	DefaultTemplate string              `json:"default_template"`
-	Templates       []string            `json:"templates"`
-	Variables       []string            `json:"variables"`
-	Relation        map[string][]string `json:"relation"`
+type CORSConfig struct {
+	AllowOrigins     []string `json:"allow_origins"`
+	AllowMethods     []string `json:"allow_methods"`
+	AllowHeaders     []string `json:"allow_headers"`
+	ExposeHeaders    []string `json:"expose_headers"`
+	AllowCredentials *bool    `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"`
 }
 
-type AddressConfig struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
-	Url  string `json:"url"`
+type StatsConfig struct {
+	Enabled    bool     `json:"enabled"`
+	Path       string   `json:"path"`
+	Token      string   `json:"token"`
+	Header     string   `json:"header"`
+	Windows    []string `json:"windows"`
+	MaxSamples int      `json:"max_samples"`
+	Dashboard  string   `json:"dashboard"`
 }
 
-type GraphsConfig struct {
-	API              string   `json:"api"`
-	Graph            []string `json:"graph"`
-	IsProxy          bool     `json:"is_proxy,omitempty"`
-	ErrorAddressName string   `json:"error_address_name"`
+type AccessLogConfig struct {
+	Enabled        bool     `json:"enabled"`
+	File           string   `json:"file"`
+	Fields         []string `json:"fields"`
+	MaxSize        int      `json:"max_size"`
+	MaxBackups     int      `json:"max_backups"`
+	BodySampleRate float64  `json:"body_sample_rate"`
+	BodyMaxSize    int      `json:"body_max_size"`
 }
 
-func parseRefer(url string) (protocol string, domain string) {
-	url = strings.TrimSpace(url)
+type XDomainConfig struct {
+	Disabled    bool   `json:"disabled"`
+	Prefix      string `json:"prefix"`
+	MaxAge      int    `json:"max_age"`
+	PostMessage bool   `json:"post_message"`
+}
 
-	if len(url) > 0 {
-		start0 := strings.Index(url, "://")
-		url0 := url[start0+3 : len(url)]
-		surls := strings.Split(url0, "/")
+type MultiCallConfig struct {
+	MaxBatchSize   int   `json:"max_batch_size"`
+	SubCallTimeout int64 `json:"sub_call_timeout"`
+}
 
-		if len(surls) > 0 {
-			domain = surls[0]
-		}
+type JSONRPCConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Path         string `json:"path"`
+	MaxBatchSize int    `json:"max_batch_size"`
+}
 
-		protocol = url[0:start0]
-	}
+type AsyncConfig struct {
+	Path    string `json:"path"`
+	Store   string `json:"store"`
+	Dir     string `json:"dir"`
+	TTL     int64  `json:"ttl"`
+	Timeout int64  `json:"timeout"`
+}
 
-	return
+type WebhookConfig struct {
+	MaxRetries    int    `json:"max_retries"`
+	Backoff       int64  `json:"backoff"`
+	MaxBackoff    int64  `json:"max_backoff"`
+	Timeout       int64  `json:"timeout"`
+	DeadLetter    string `json:"dead_letter"`
+	AllowUnsigned bool   `json:"allow_unsigned"`
 }
 
-func (p *HTTPConfig) ParseOrigin(refer string) (protocol string, domin string, isAllow bool) {
-	if _, err := url.Parse(refer); err == nil {
-		refProtocol, refDomain := parseRefer(refer)
-		if p.allowOrigins["*"] ||
-			p.allowOrigins[refDomain] {
-			return refProtocol, refDomain, true
-		}
-		return refProtocol, refDomain, false
-	}
+type StreamConfig struct {
+	Enabled    bool   `json:"enabled"`
+	Path       string `json:"path"`
+	Heartbeat  int64  `json:"heartbeat"`
+	MaxEvents  int    `json:"max_events"`
+	TTL        int64  `json:"ttl"`
+	MaxStreams int    `json:"max_streams"`
+}
 
-	return "", "", false
+type WebSocketConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Path           string `json:"path"`
+	Token          string `json:"token"`
+	Header         string `json:"header"`
+	MaxInflight    int    `json:"max_inflight"`
+	MaxPending     int    `json:"max_pending"`
+	MaxMessageSize int64  `json:"max_message_size"`
+	PingInterval   int64  `json:"ping_interval"`
+	MaxSessions    int32  `json:"max_sessions"`
 }
 
-func (p *HTTPConfig) allowHeaders() string {
-	if p._AllowHeaders != "" {
-		return p._AllowHeaders
-	}
+type IdempotencyConfig struct {
+	Header          string `json:"header"`
+	PrincipalHeader string `json:"principal_header"`
+	TTL             int64  `json:"ttl"`
+}
 
-	if p.AllowHeaders != nil {
-		p._AllowHeaders = strings.Join(p.AllowHeaders, ",")
-		return p._AllowHeaders
-	}
-	return ""
+type CircuitBreakerConfig struct {
+	Enabled      bool    `json:"enabled"`
+	Window       int     `json:"window"`
+	MinRequests  int     `json:"min_requests"`
+	FailureRatio float64 `json:"failure_ratio"`
+	OpenTimeout  int64   `json:"open_timeout"`
+	Probes       int     `json:"probes"`
+}
+
+type LoadSheddingConfig struct {
+	Enabled      bool  `json:"enabled"`
+	MaxInflight  int   `json:"max_inflight"`
+	MaxQueue     int   `json:"max_queue"`
+	QueueTimeout int64 `json:"queue_timeout"`
+	RetryAfter   int   `json:"retry_after"`
+}
+
+type TLSConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Address      string   `json:"address"`
+	CertFile     string   `json:"cert_file"`
+	KeyFile      string   `json:"key_file"`
+	MinVersion   string   `json:"min_version"`
+	Ciphers      []string `json:"ciphers"`
+	ClientCAFile string   `json:"client_ca_file"`
+	ClientAuth   string   `json:"client_auth"`
+}
+
+type HealthConfig struct {
+	CallbackThreshold int64 `json:"callback_threshold"`
+}
+
+type ShutdownConfig struct {
+	Timeout int64 `json:"timeout"`
+}
+
+type RetryConfig struct {
+	MaxAttempts int      `json:"max_attempts"`
+	Backoff     int64    `json:"backoff"`
+	MaxBackoff  int64    `json:"max_backoff"`
+	HedgeDelay  int64    `json:"hedge_delay"`
+	RetryOn     []string `json:"retry_on"`
+}
+
+type IPFilterConfig struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+type RedactConfig struct {
+	Keys  []string `json:"keys"`
+	Paths []string `json:"paths"`
+	Mask  string   `json:"mask"`
+}
+
+type RendererConfig struct {
+	DefaultTemplate string              `json:"default_template"`
+	Templates       []string            `json:"templates"`
+	Variables       []string            `json:"variables"`
+	Relation        map[string][]string `json:"relation"`
+}
+
+type AddressConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+type GraphsConfig struct {
+	API              string         `json:"api"`
+	Graph            []string       `json:"graph"`
+	IsProxy          bool           `json:"is_proxy,omitempty"`
+	Async            bool           `json:"async,omitempty"`
+	WebhookAllowlist []string       `json:"webhook_allowlist,omitempty"`
+	Idempotent       bool           `json:"idempotent,omitempty"`
+	SafeToRetry      bool           `json:"safe_to_retry,omitempty"`
+	Retry            *RetryConfig   `json:"retry,omitempty"`
+	MaxInflight      int            `json:"max_inflight,omitempty"`
+	Timeout          int64          `json:"timeout,omitempty"`
+	MinTimeout       int64          `json:"min_timeout,omitempty"`
+	MaxTimeout       int64          `json:"max_timeout,omitempty"`
+	MaxAsyncTimeout  int64          `json:"max_async_timeout,omitempty"`
+	ErrorAddressName string         `json:"error_address_name"`
+	Redact           RedactConfig   `json:"redact"`
+	IPFilter         IPFilterConfig `json:"ip_filter"`
+	CORS             *CORSConfig    `json:"cors,omitempty"`
 }
 
 func isFileOrDir(filename string, decideDir bool) bool {
@@ -178,12 +313,6 @@ func LoadConfig(filename string) InletHTTPAPIConfig {
 		panic(e)
 	}
 
-	conf.HTTP.allowOrigins = make(map[string]bool)
-
-	for _, allowOrigin := range conf.HTTP.AllowOrigins {
-		conf.HTTP.allowOrigins[allowOrigin] = true
-	}
-
 	if conf.HTTP.ResponseHeaders == nil {
 		conf.HTTP.ResponseHeaders = make(map[string]string)
 	}
@@ -194,6 +323,235 @@ func LoadConfig(filename string) InletHTTPAPIConfig {
 		}
 	}
 
+	if conf.HTTP.APIHeader == "" {
+		conf.HTTP.APIHeader = API_HEADER
+	}
+
+	if conf.HTTP.Timeout <= 0 {
+		conf.HTTP.Timeout = DEFAULT_API_TIMEOUT
+	}
+
+	if conf.HTTP.Stats.Path == "" {
+		conf.HTTP.Stats.Path = DEFAULT_STATS_PATH
+	} else if conf.HTTP.Stats.Path[0] != '/' {
+		conf.HTTP.Stats.Path = "/" + conf.HTTP.Stats.Path
+	}
+
+	if conf.HTTP.Stats.Header == "" {
+		conf.HTTP.Stats.Header = DEFAULT_STATS_HEADER
+	}
+
+	if conf.HTTP.Stats.Dashboard == "" {
+		conf.HTTP.Stats.Dashboard = DEFAULT_STATS_DASHBOARD
+	}
+
+	if conf.HTTP.AccessLog.File == "" {
+		conf.HTTP.AccessLog.File = DEFAULT_ACCESS_LOG_FILE
+	}
+
+	if conf.HTTP.AccessLog.MaxSize <= 0 {
+		conf.HTTP.AccessLog.MaxSize = DEFAULT_ACCESS_LOG_MAX_SIZE
+	}
+
+	if conf.HTTP.AccessLog.MaxBackups <= 0 {
+		conf.HTTP.AccessLog.MaxBackups = DEFAULT_ACCESS_LOG_MAX_BACKUPS
+	}
+
+	if conf.HTTP.AccessLog.BodyMaxSize <= 0 {
+		conf.HTTP.AccessLog.BodyMaxSize = DEFAULT_ACCESS_LOG_BODY_MAX_SIZE
+	}
+
+	conf.HTTP.XDomain.Prefix = "/" + strings.Trim(conf.HTTP.XDomain.Prefix, "/")
+	if conf.HTTP.XDomain.Prefix == "/" {
+		conf.HTTP.XDomain.Prefix = DEFAULT_XDOMAIN_PREFIX
+	}
+
+	if conf.HTTP.XDomain.MaxAge <= 0 {
+		conf.HTTP.XDomain.MaxAge = DEFAULT_XDOMAIN_MAX_AGE
+	}
+
+	if conf.HTTP.MultiCall.MaxBatchSize <= 0 {
+		conf.HTTP.MultiCall.MaxBatchSize = DEFAULT_MULTI_CALL_MAX_BATCH_SIZE
+	}
+
+	if conf.HTTP.JSONRPC.Path == "" {
+		conf.HTTP.JSONRPC.Path = DEFAULT_JSONRPC_PATH
+	}
+
+	if conf.HTTP.JSONRPC.MaxBatchSize <= 0 {
+		conf.HTTP.JSONRPC.MaxBatchSize = DEFAULT_JSONRPC_MAX_BATCH_SIZE
+	}
+
+	if conf.HTTP.Async.Path == "" {
+		conf.HTTP.Async.Path = DEFAULT_ASYNC_PATH
+	}
+
+	if conf.HTTP.Async.Dir == "" {
+		conf.HTTP.Async.Dir = DEFAULT_ASYNC_DIR
+	}
+
+	if conf.HTTP.Async.TTL <= 0 {
+		conf.HTTP.Async.TTL = DEFAULT_ASYNC_TTL
+	}
+
+	if conf.HTTP.Async.Timeout <= 0 {
+		conf.HTTP.Async.Timeout = DEFAULT_ASYNC_TIMEOUT
+	}
+
+	if conf.HTTP.Webhook.MaxRetries < 0 {
+		conf.HTTP.Webhook.MaxRetries = 0
+	} else if conf.HTTP.Webhook.MaxRetries == 0 {
+		conf.HTTP.Webhook.MaxRetries = DEFAULT_WEBHOOK_MAX_RETRIES
+	}
+
+	if conf.HTTP.Webhook.Backoff <= 0 {
+		conf.HTTP.Webhook.Backoff = DEFAULT_WEBHOOK_BACKOFF
+	}
+
+	if conf.HTTP.Webhook.MaxBackoff <= 0 {
+		conf.HTTP.Webhook.MaxBackoff = DEFAULT_WEBHOOK_MAX_BACKOFF
+	}
+
+	if conf.HTTP.Webhook.Timeout <= 0 {
+		conf.HTTP.Webhook.Timeout = DEFAULT_WEBHOOK_TIMEOUT
+	}
+
+	if conf.HTTP.Webhook.DeadLetter == "" {
+		conf.HTTP.Webhook.DeadLetter = DEFAULT_WEBHOOK_DEAD_LETTER
+	}
+
+	if conf.HTTP.Stream.Path == "" {
+		conf.HTTP.Stream.Path = DEFAULT_STREAM_PATH
+	}
+
+	if conf.HTTP.Stream.Heartbeat <= 0 {
+		conf.HTTP.Stream.Heartbeat = DEFAULT_STREAM_HEARTBEAT
+	}
+
+	if conf.HTTP.Stream.MaxEvents <= 0 {
+		conf.HTTP.Stream.MaxEvents = DEFAULT_STREAM_MAX_EVENTS
+	}
+
+	if conf.HTTP.Stream.TTL <= 0 {
+		conf.HTTP.Stream.TTL = DEFAULT_STREAM_TTL
+	}
+
+	if conf.HTTP.Stream.MaxStreams <= 0 {
+		conf.HTTP.Stream.MaxStreams = DEFAULT_STREAM_MAX_STREAMS
+	}
+
+	if conf.HTTP.WebSocket.Path == "" {
+		conf.HTTP.WebSocket.Path = DEFAULT_WEBSOCKET_PATH
+	}
+
+	if conf.HTTP.WebSocket.Header == "" {
+		conf.HTTP.WebSocket.Header = DEFAULT_WEBSOCKET_HEADER
+	}
+
+	if conf.HTTP.WebSocket.MaxInflight <= 0 {
+		conf.HTTP.WebSocket.MaxInflight = DEFAULT_WEBSOCKET_MAX_INFLIGHT
+	}
+
+	if conf.HTTP.WebSocket.MaxPending <= 0 {
+		conf.HTTP.WebSocket.MaxPending = DEFAULT_WEBSOCKET_MAX_PENDING
+	}
+
+	if conf.HTTP.WebSocket.MaxMessageSize <= 0 {
+		conf.HTTP.WebSocket.MaxMessageSize = DEFAULT_WEBSOCKET_MAX_MESSAGE_SIZE
+	}
+
+	if conf.HTTP.WebSocket.PingInterval <= 0 {
+		conf.HTTP.WebSocket.PingInterval = DEFAULT_WEBSOCKET_PING_INTERVAL
+	}
+
+	if conf.HTTP.WebSocket.MaxSessions <= 0 {
+		conf.HTTP.WebSocket.MaxSessions = DEFAULT_WEBSOCKET_MAX_SESSIONS
+	}
+
+	if conf.HTTP.Idempotency.Header == "" {
+		conf.HTTP.Idempotency.Header = DEFAULT_IDEMPOTENCY_HEADER
+	}
+
+	if conf.HTTP.Idempotency.PrincipalHeader == "" {
+		conf.HTTP.Idempotency.PrincipalHeader = DEFAULT_IDEMPOTENCY_PRINCIPAL_HEADER
+	}
+
+	if conf.HTTP.Idempotency.TTL <= 0 {
+		conf.HTTP.Idempotency.TTL = DEFAULT_IDEMPOTENCY_TTL
+	}
+
+	if conf.HTTP.CircuitBreaker.Window <= 0 {
+		conf.HTTP.CircuitBreaker.Window = DEFAULT_CIRCUIT_WINDOW
+	}
+
+	if conf.HTTP.CircuitBreaker.MinRequests <= 0 {
+		conf.HTTP.CircuitBreaker.MinRequests = DEFAULT_CIRCUIT_MIN_REQUESTS
+	}
+
+	if conf.HTTP.CircuitBreaker.FailureRatio <= 0 || conf.HTTP.CircuitBreaker.FailureRatio > 1 {
+		conf.HTTP.CircuitBreaker.FailureRatio = DEFAULT_CIRCUIT_FAILURE_RATIO
+	}
+
+	if conf.HTTP.CircuitBreaker.OpenTimeout <= 0 {
+		conf.HTTP.CircuitBreaker.OpenTimeout = DEFAULT_CIRCUIT_OPEN_TIMEOUT
+	}
+
+	if conf.HTTP.CircuitBreaker.Probes <= 0 {
+		conf.HTTP.CircuitBreaker.Probes = DEFAULT_CIRCUIT_PROBES
+	}
+
+	if conf.HTTP.LoadShedding.MaxInflight <= 0 {
+		conf.HTTP.LoadShedding.MaxInflight = DEFAULT_SHEDDING_MAX_INFLIGHT
+	}
+
+	if conf.HTTP.LoadShedding.MaxQueue <= 0 {
+		conf.HTTP.LoadShedding.MaxQueue = DEFAULT_SHEDDING_MAX_QUEUE
+	}
+
+	if conf.HTTP.LoadShedding.QueueTimeout <= 0 {
+		conf.HTTP.LoadShedding.QueueTimeout = DEFAULT_SHEDDING_QUEUE_TIMEOUT
+	}
+
+	if conf.HTTP.LoadShedding.RetryAfter <= 0 {
+		conf.HTTP.LoadShedding.RetryAfter = DEFAULT_SHEDDING_RETRY_AFTER
+	}
+
+	if conf.HTTP.Shutdown.Timeout <= 0 {
+		conf.HTTP.Shutdown.Timeout = DEFAULT_SHUTDOWN_TIMEOUT
+	}
+
+	if conf.HTTP.Health.CallbackThreshold <= 0 {
+		conf.HTTP.Health.CallbackThreshold = DEFAULT_HEALTH_CALLBACK_THRESHOLD
+	}
+
+	if conf.HTTP.TLS.MinVersion == "" {
+		conf.HTTP.TLS.MinVersion = DEFAULT_TLS_MIN_VERSION
+	}
+
+	if conf.HTTP.TLS.ClientAuth == "" {
+		conf.HTTP.TLS.ClientAuth = DEFAULT_TLS_CLIENT_AUTH
+	}
+
+	if conf.HTTP.TLS.Enabled {
+		if conf.HTTP.TLS.Address == "" {
+			panic(errors.New("tls address could not be empty while tls is enabled"))
+		}
+
+		if conf.HTTP.TLS.CertFile == "" || conf.HTTP.TLS.KeyFile == "" {
+			panic(errors.New("tls cert file and key file could not be empty while tls is enabled"))
+		}
+
+		if !isLoopbackAddress(conf.HTTP.Address) {
+			panic(fmt.Errorf("http address %s should be a loopback address while tls is enabled", conf.HTTP.Address))
+		}
+	}
+
+	if trustedProxies, e := parseCIDRs(conf.HTTP.TrustedProxies); e != nil {
+		panic(fmt.Errorf("parse trusted proxies failed, error: %s", e))
+	} else {
+		conf.HTTP.trustedProxies = trustedProxies
+	}
+
 	if conf.HTTP.Server == "" {
 		conf.HTTP.ResponseHeaders["Server"] = conf.HTTP.Server
 	} else {
@@ -228,6 +586,14 @@ func LoadConfig(filename string) InletHTTPAPIConfig {
 		if graph.IsProxy {
 			proxyAPI[graph.API] = true
 		}
+
+		if graph.Async {
+			asyncAPI[graph.API] = true
+		}
+
+		if graph.Idempotent {
+			idempotentAPI[graph.API] = true
+		}
 	}
 
 	internalAllowHeaders := []string{
@@ -239,7 +605,12 @@ func LoadConfig(filename string) InletHTTPAPIConfig {
 		"X-Api",
 		"X-Api-Multi-Call",
 		"X-Api-Call-Timeout",
-		API_RANGE}
+		SUB_CALL_TIMEOUT,
+		API_FIELDS_HEADER,
+		API_CALLBACK_URL_HEADER,
+		"Last-Event-ID",
+		API_RANGE,
+		REQUEST_ID_HEADER}
 
 	if conf.HTTP.Signature.Enabled {
 
@@ -266,6 +637,14 @@ func LoadConfig(filename string) InletHTTPAPIConfig {
 		internalAllowHeaders = append(internalAllowHeaders, conf.HTTP.Signature.Header)
 	}
 
+	if conf.HTTP.Stats.Enabled && conf.HTTP.Stats.Token != "" {
+		internalAllowHeaders = append(internalAllowHeaders, conf.HTTP.Stats.Header)
+	}
+
+	if len(idempotentAPI) > 0 {
+		internalAllowHeaders = append(internalAllowHeaders, conf.HTTP.Idempotency.Header)
+	}
+
 	if conf.HTTP.APIHeader != "" {
 		internalAllowHeaders = append(internalAllowHeaders, conf.HTTP.APIHeader)
 	}
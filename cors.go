@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEFAULT_CORS_MAX_AGE = 600
+)
+
+var (
+	DefaultCORSMethods = []string{METHOD_POST}
+)
+
+type originPattern struct {
+	Scheme    string
+	Host      string
+	Port      string
+	Subdomain bool
+}
+
+func parseOriginPattern(pattern string) (origin originPattern, err error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		err = fmt.Errorf("empty origin pattern")
+		return
+	}
+
+	if i := strings.Index(pattern, "://"); i >= 0 {
+		origin.Scheme = strings.ToLower(pattern[:i])
+		pattern = pattern[i+3:]
+	}
+
+	hostPort := strings.TrimRight(pattern, "/")
+	host := hostPort
+
+	if h, port, e := net.SplitHostPort(hostPort); e == nil {
+		host = h
+		if port == "*" {
+			origin.Port = port
+		} else if origin.Port, err = normalizePort(origin.Scheme, port); err != nil {
+			return
+		}
+	}
+
+	if strings.HasPrefix(host, "*.") {
+		origin.Subdomain = true
+		host = host[2:]
+	}
+
+	if origin.Host, err = normalizeHost(host); err != nil {
+		err = fmt.Errorf("bad origin pattern %s, error: %s", pattern, err)
+		return
+	}
+
+	return
+}
+
+func (p originPattern) Match(origin Origin) bool {
+	if p.Scheme != "" && p.Scheme != origin.Scheme {
+		return false
+	}
+
+	switch {
+	case p.Port == "*":
+	case p.Scheme != "" || p.Port == "":
+		if p.Port != origin.Port {
+			return false
+		}
+	default:
+		if p.Port != origin.Port && !(origin.Port == "" && p.Port == defaultPort(origin.Scheme)) {
+			return false
+		}
+	}
+
+	if p.Subdomain {
+		return strings.HasSuffix(origin.Host, "."+p.Host)
+	}
+
+	return origin.Host == p.Host
+}
+
+type CORSPolicy struct {
+	anyOrigin   bool
+	origins     []originPattern
+	methods     map[string]bool
+	headers     map[string]bool
+	credentials bool
+
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
+}
+
+func newCORSPolicy(corsConf CORSConfig) (policy *CORSPolicy, err error) {
+	policy = &CORSPolicy{
+		methods: make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+
+	for _, pattern := range corsConf.AllowOrigins {
+		if strings.TrimSpace(pattern) == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+
+		var origin originPattern
+		if origin, err = parseOriginPattern(pattern); err != nil {
+			return
+		}
+		policy.origins = append(policy.origins, origin)
+	}
+
+	allowMethods := corsConf.AllowMethods
+	if len(allowMethods) == 0 {
+		allowMethods = DefaultCORSMethods
+	}
+
+	methods := []string{}
+	for _, method := range allowMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		policy.methods[method] = true
+		methods = append(methods, method)
+	}
+
+	for _, header := range corsConf.AllowHeaders {
+		policy.headers[strings.ToLower(strings.TrimSpace(header))] = true
+	}
+
+	policy.credentials = corsConf.AllowCredentials != nil && *corsConf.AllowCredentials
+	if policy.credentials && policy.anyOrigin {
+		err = fmt.Errorf("allow_credentials could not be used together with the * origin")
+		return
+	}
+
+	policy.allowMethods = strings.Join(methods, ",")
+	policy.allowHeaders = strings.Join(corsConf.AllowHeaders, ",")
+	policy.exposeHeaders = strings.Join(corsConf.ExposeHeaders, ",")
+
+	if corsConf.MaxAge > 0 {
+		policy.maxAge = strconv.Itoa(corsConf.MaxAge)
+	}
+
+	return
+}
+
+func (p *CORSPolicy) AllowOrigin(value string) bool {
+	origin, err := ParseOrigin(value)
+	if err != nil {
+		return false
+	}
+
+	if p.anyOrigin {
+		return true
+	}
+
+	for _, pattern := range p.origins {
+		if pattern.Match(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *CORSPolicy) AllowPreflight(r *http.Request) bool {
+	method := strings.ToUpper(strings.TrimSpace(r.Header.Get("Access-Control-Request-Method")))
+	if method == "" || !p.methods[method] {
+		return false
+	}
+
+	for _, header := range splitHeaderValues(r.Header["Access-Control-Request-Headers"]) {
+		if header != "" && !p.headers[strings.ToLower(header)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *CORSPolicy) writeOriginHeaders(w http.ResponseWriter, origin string) {
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+type CORSPolicies struct {
+	global *CORSPolicy
+	apis   map[string]*CORSPolicy
+}
+
+func mergeCORSConfig(base, override CORSConfig) CORSConfig {
+	if len(override.AllowOrigins) > 0 {
+		base.AllowOrigins = override.AllowOrigins
+	}
+	if len(override.AllowMethods) > 0 {
+		base.AllowMethods = override.AllowMethods
+	}
+	if len(override.AllowHeaders) > 0 {
+		base.AllowHeaders = append(append([]string{}, base.AllowHeaders...), override.AllowHeaders...)
+	}
+	if len(override.ExposeHeaders) > 0 {
+		base.ExposeHeaders = append(append([]string{}, base.ExposeHeaders...), override.ExposeHeaders...)
+	}
+	if override.AllowCredentials != nil {
+		base.AllowCredentials = override.AllowCredentials
+	}
+	if override.MaxAge != 0 {
+		base.MaxAge = override.MaxAge
+	}
+	return base
+}
+
+func mergeHeaders(lists ...[]string) (headers []string) {
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, header := range list {
+			if key := strings.ToLower(strings.TrimSpace(header)); key != "" && !seen[key] {
+				seen[key] = true
+				headers = append(headers, strings.TrimSpace(header))
+			}
+		}
+	}
+	return
+}
+
+func NewCORSPolicies(httpConf HTTPConfig, graphConf []GraphsConfig) (policies *CORSPolicies, err error) {
+	globalConf := httpConf.CORS
+	globalConf.AllowOrigins = append(append([]string{}, httpConf.AllowOrigins...), globalConf.AllowOrigins...)
+	globalConf.AllowHeaders = mergeHeaders(httpConf.AllowHeaders, globalConf.AllowHeaders)
+	globalConf.ExposeHeaders = append([]string{REQUEST_ID_HEADER, API_EFFECTIVE_TIMEOUT_HEADER}, globalConf.ExposeHeaders...)
+	if httpConf.Signature.Enabled {
+		globalConf.ExposeHeaders = append(globalConf.ExposeHeaders, httpConf.Signature.Header)
+	}
+	for _, graph := range graphConf {
+		if graph.Retry != nil {
+			globalConf.ExposeHeaders = append(globalConf.ExposeHeaders, API_ATTEMPTS_HEADER)
+			break
+		}
+	}
+	if globalConf.MaxAge == 0 {
+		globalConf.MaxAge = DEFAULT_CORS_MAX_AGE
+	}
+
+	policies = &CORSPolicies{
+		apis: make(map[string]*CORSPolicy),
+	}
+
+	if policies.global, err = newCORSPolicy(globalConf); err != nil {
+		return
+	}
+
+	for _, graph := range graphConf {
+		if graph.CORS == nil {
+			continue
+		}
+
+		var policy *CORSPolicy
+		if policy, err = newCORSPolicy(mergeCORSConfig(globalConf, *graph.CORS)); err != nil {
+			err = fmt.Errorf("bad cors config of api %s, error: %s", graph.API, err)
+			return
+		}
+		policies.apis[graph.API] = policy
+	}
+
+	return
+}
+
+func (p *CORSPolicies) Policy(apiName string) *CORSPolicy {
+	if policy, exist := p.apis[apiName]; exist {
+		return policy
+	}
+	return p.global
+}
+
+func (p *CORSPolicies) WriteHeaders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+
+	policy := p.Policy(requestAPIName(r))
+	if !policy.AllowOrigin(origin) {
+		return
+	}
+
+	policy.writeOriginHeaders(w, origin)
+	if policy.exposeHeaders != "" {
+		w.Header().Set("Access-Control-Expose-Headers", policy.exposeHeaders)
+	}
+}
+
+func (p *CORSPolicies) Preflight(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	policy := p.Policy(requestAPIName(r))
+
+	w.Header().Add("Vary", "Origin")
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if origin == "" || !policy.AllowOrigin(origin) || !policy.AllowPreflight(r) {
+		return false
+	}
+
+	policy.writeOriginHeaders(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", policy.allowMethods)
+	if policy.allowHeaders != "" {
+		w.Header().Set("Access-Control-Allow-Headers", policy.allowHeaders)
+	}
+	if policy.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", policy.maxAge)
+	}
+
+	return true
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewCORSPoliciesAllowHeaders(t *testing.T) {
+	httpConf := HTTPConfig{
+		AllowHeaders: []string{"Content-Type", "X-Api"},
+		CORS:         CORSConfig{AllowOrigins: []string{"https://example.com"}, AllowHeaders: []string{"X-Tenant", "content-type"}},
+	}
+
+	policies, err := NewCORSPolicies(httpConf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		headers string
+		allowed bool
+	}{
+		{headers: "Content-Type", allowed: true},
+		{headers: "X-Api", allowed: true},
+		{headers: "x-tenant", allowed: true},
+		{headers: "X-Tenant, X-Api", allowed: true},
+		{headers: "X-Other", allowed: false},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest(METHOD_OPTIONS, "/", nil)
+		r.Header.Set("Access-Control-Request-Method", METHOD_POST)
+		r.Header.Set("Access-Control-Request-Headers", test.headers)
+
+		if allowed := policies.global.AllowPreflight(r); allowed != test.allowed {
+			t.Errorf("AllowPreflight(%q) = %v, want %v", test.headers, allowed, test.allowed)
+		}
+	}
+
+	if expected := "Content-Type,X-Api,X-Tenant"; policies.global.allowHeaders != expected {
+		t.Errorf("allow headers = %q, want %q", policies.global.allowHeaders, expected)
+	}
+}
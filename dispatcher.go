@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gogap/errors"
+	"github.com/gogap/logs"
+	"github.com/spirit-contrib/inlet_http"
+)
+
+const (
+	SUB_CALL_TIMEOUT_GRACE = time.Second
+)
+
+type APICall struct {
+	Name    string
+	API     string
+	Content interface{}
+	Body    []byte
+	Timeout time.Duration
+}
+
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (p *discardResponseWriter) Header() http.Header {
+	return p.header
+}
+
+func (p *discardResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (p *discardResponseWriter) WriteHeader(code int) {
+}
+
+type APIDispatcher struct {
+	inletHTTP *inlet_http.InletHTTP
+}
+
+func NewAPIDispatcher(inletHTTP *inlet_http.InletHTTP) *APIDispatcher {
+	return &APIDispatcher{
+		inletHTTP: inletHTTP,
+	}
+}
+
+func newSubRequest(parent *http.Request, call APICall) (sub *http.Request, ctx *APIRequestContext, err error) {
+	body := call.Body
+	if _, isMap := call.Content.(map[string]interface{}); isMap && body == nil {
+		if body, err = json.Marshal(call.Content); err != nil {
+			err = ERR_MARSHAL_STRUCT_ERROR.New(errors.Params{"err": err})
+			return
+		}
+	}
+
+	if sub, err = http.NewRequest(METHOD_POST, conf.HTTP.PATH, bytes.NewReader(body)); err != nil {
+		return
+	}
+
+	for key, values := range parent.Header {
+		sub.Header[key] = append([]string{}, values...)
+	}
+
+	sub.Header.Del(MULTI_CALL)
+	sub.Header.Del(SUB_CALL_TIMEOUT)
+	sub.Header.Del("Content-Length")
+	sub.Header.Set(conf.HTTP.APIHeader, call.API)
+	sub.Header.Set("Content-Type", "application/json")
+
+	if call.Timeout > 0 {
+		sub.Header.Set(API_CALL_TIMEOUT, strconv.FormatInt(int64(call.Timeout/time.Millisecond), 10))
+	}
+
+	sub.RequestURI = conf.HTTP.PATH
+	sub.RemoteAddr = parent.RemoteAddr
+	sub.Host = parent.Host
+	sub.TLS = parent.TLS
+
+	parentId := requestId(parent)
+
+	streamId := ""
+	if parentCtx := apiRequestContext(parent); parentCtx != nil {
+		streamId = parentCtx.StreamId
+	}
+
+	ctx = &APIRequestContext{
+		StartTime:  time.Now(),
+		RequestId:  parentId + "/" + call.Name,
+		StreamId:   streamId,
+		SubCall:    true,
+		Content:    call.Content,
+		HasContent: call.Body == nil,
+	}
+
+	sub.Header.Set(REQUEST_ID_HEADER, ctx.RequestId)
+	sub = sub.WithContext(withAPIContextValue(parent.Context(), ctx))
+
+	return
+}
+
+func (p *APIDispatcher) Call(parent *http.Request, call APICall) (resp APIResponse) {
+	resp, _ = p.CallAttempts(parent, call)
+	return
+}
+
+func (p *APIDispatcher) CallAttempts(parent *http.Request, call APICall) (resp APIResponse, attempts int) {
+	if policy, exist := retryPolicies[call.API]; exist {
+		return p.callWithPolicy(parent, call, policy)
+	}
+	return p.call(parent, call, 0), 1
+}
+
+func (p *APIDispatcher) call(parent *http.Request, call APICall, attempt int) (resp APIResponse) {
+	sub, ctx, err := newSubRequest(parent, call)
+	if err != nil {
+		return newErrorAPIResponse(err)
+	}
+
+	ctx.Attempt = attempt
+
+	release := func() {}
+	if loadShedder != nil {
+		var ok bool
+		if release, ok = loadShedder.AcquireCall(parent, call.API); !ok {
+			logs.Warn("sub call shed, api:", call.API, "request id:", ctx.RequestId)
+			recordShedStats(call.API)
+			return newErrorAPIResponse(ERR_API_OVERLOADED.New(errors.Params{"api": call.API}))
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer release()
+		defer func() {
+			if e := recover(); e != nil {
+				logs.Error("sub call panic, api:", call.API, "error:", e)
+				done <- fmt.Errorf("%v", e)
+			}
+		}()
+
+		healthMonitor.Track(p.inletHTTP.Handler)(&discardResponseWriter{header: make(http.Header)}, sub)
+		done <- nil
+	}()
+
+	var timeout <-chan time.Time
+	if call.Timeout > 0 {
+		timer := time.NewTimer(call.Timeout + SUB_CALL_TIMEOUT_GRACE)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err = <-done:
+		if err != nil {
+			return newErrorAPIResponse(err)
+		}
+	case <-timeout:
+		return newErrorAPIResponse(ERR_API_REQUEST_TIMEOUT.New(errors.Params{"api": call.API}))
+	}
+
+	if resp, exist := ctx.Responses[call.API]; exist {
+		return resp
+	}
+
+	return newErrorAPIResponse(ERR_API_NO_RESPONSE.New(errors.Params{"api": call.API}))
+}
+
+func (p *APIDispatcher) CallAll(parent *http.Request, calls []APICall) map[string]APIResponse {
+	responses := make(map[string]APIResponse)
+
+	var locker sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, call := range calls {
+		wg.Add(1)
+		go func(call APICall) {
+			defer wg.Done()
+
+			resp := p.Call(parent, call)
+
+			locker.Lock()
+			responses[call.Name] = resp
+			locker.Unlock()
+		}(call)
+	}
+
+	wg.Wait()
+
+	return responses
+}
+
+func readRequestBody(r *http.Request) (body []byte, err error) {
+	if r.Body == nil {
+		return
+	}
+
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		return
+	}
+
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return
+}
+
+func parseTimeoutHeader(r *http.Request, header string) time.Duration {
+	if value := r.Header.Get(header); value != "" {
+		if ms, e := strconv.ParseInt(value, 10, 64); e == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return 0
+}
@@ -33,4 +33,32 @@ var (
 	ERR_TMPL_VAR_ALREADY_EXIST = errors.TN(INLET_HTTP_API_ERR_NS, 20, "template vars already exist, key: {{.key}}, value: {{.value}}")
 	ERR_TEMPLATE_NOT_EXIST     = errors.TN(INLET_HTTP_API_ERR_NS, 21, "template not exist, name: {{.name}}")
 	ERR_API_ALREADY_RELATED    = errors.TN(INLET_HTTP_API_ERR_NS, 22, "api {{.apiName}} already with template {{.tmplName}}")
+
+	ERR_STATS_UNAUTHORIZED = errors.TN(INLET_HTTP_API_ERR_NS, 23, "stats token is missing or invalid")
+	ERR_API_IP_BLOCKED     = errors.TN(INLET_HTTP_API_ERR_NS, 24, "api could not be called from this address, api: {{.api}}, ip: {{.ip}}")
+
+	ERR_MULTI_CALL_TOO_MANY_APIS = errors.TN(INLET_HTTP_API_ERR_NS, 25, "too many apis in multi call, count: {{.count}}, max: {{.max}}")
+	ERR_API_NO_RESPONSE          = errors.TN(INLET_HTTP_API_ERR_NS, 26, "api finished without response, api: {{.api}}")
+
+	ERR_MULTI_CALL_CYCLIC_REF     = errors.TN(INLET_HTTP_API_ERR_NS, 27, "cyclic reference in multi call, apis: {{.apis}}")
+	ERR_MULTI_CALL_UNRESOLVED_REF = errors.TN(INLET_HTTP_API_ERR_NS, 28, "unresolved reference in multi call, api: {{.api}}, ref: {{.ref}}, reason: {{.reason}}")
+
+	ERR_MULTI_CALL_BAD_ALIAS       = errors.TN(INLET_HTTP_API_ERR_NS, 29, "bad alias in multi call, alias: {{.alias}}")
+	ERR_MULTI_CALL_DUPLICATE_ALIAS = errors.TN(INLET_HTTP_API_ERR_NS, 30, "duplicate alias in multi call, alias: {{.alias}}")
+
+	ERR_ASYNC_JOB_STORE_FAILED = errors.TN(INLET_HTTP_API_ERR_NS, 31, "async job store failed, error: {{.err}}")
+	ERR_ASYNC_JOB_NOT_FOUND    = errors.TN(INLET_HTTP_API_ERR_NS, 32, "async job not found or expired, job: {{.job}}")
+	ERR_WEBHOOK_NOT_ALLOWED    = errors.TN(INLET_HTTP_API_ERR_NS, 33, "webhook url is not allowed, api: {{.api}}, url: {{.url}}")
+	ERR_STREAM_NOT_FOUND       = errors.TN(INLET_HTTP_API_ERR_NS, 34, "stream not found or expired")
+	ERR_WEBSOCKET_UNAUTHORIZED = errors.TN(INLET_HTTP_API_ERR_NS, 35, "websocket token is missing or invalid")
+	ERR_WEBSOCKET_BAD_FRAME    = errors.TN(INLET_HTTP_API_ERR_NS, 36, "bad websocket frame, error: {{.err}}")
+
+	ERR_IDEMPOTENCY_KEY_INVALID = errors.TN(INLET_HTTP_API_ERR_NS, 37, "idempotency key is invalid, api: {{.api}}")
+	ERR_IDEMPOTENCY_KEY_REUSED  = errors.TN(INLET_HTTP_API_ERR_NS, 38, "idempotency key was already used with a different request body, api: {{.api}}")
+	ERR_IDEMPOTENCY_IN_PROGRESS = errors.TN(INLET_HTTP_API_ERR_NS, 39, "request with the same idempotency key is still in progress, api: {{.api}}")
+	ERR_API_CIRCUIT_OPEN        = errors.TN(INLET_HTTP_API_ERR_NS, 40, "circuit of downstream is open, api: {{.api}}, address: {{.address}}")
+	ERR_API_OVERLOADED          = errors.TN(INLET_HTTP_API_ERR_NS, 41, "too many requests in flight, api: {{.api}}")
+	ERR_SERVER_SHUTTING_DOWN    = errors.TN(INLET_HTTP_API_ERR_NS, 42, "server is shutting down, api: {{.api}}")
+	ERR_STREAM_TOO_MANY         = errors.TN(INLET_HTTP_API_ERR_NS, 43, "too many open streams, max: {{.max}}")
+	ERR_WEBSOCKET_TOO_MANY      = errors.TN(INLET_HTTP_API_ERR_NS, 44, "too many websocket sessions, max: {{.max}}")
 )
@@ -1,12 +1,13 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 
 	"github.com/gogap/errors"
+	"github.com/gogap/logs"
 	"github.com/gogap/spirit"
 	"github.com/spirit-contrib/inlet_http"
 )
@@ -19,16 +20,19 @@ const (
 
 	MULTI_CALL       = "X-Api-Multi-Call"
 	API_CALL_TIMEOUT = "X-Api-Call-Timeout"
+	SUB_CALL_TIMEOUT = "X-Api-Sub-Call-Timeout"
 )
 
 type APIGraphProvider struct {
 	APIHeader string
 	Path      string
 
-	apiGraph map[string]spirit.MessageGraph
+	apiGraph     map[string]spirit.MessageGraph
+	ipFilter     *ipFilter
+	apiIPFilters map[string]*ipFilter
 }
 
-func NewAPIGraphProvider(apiHeader string, path string, addressConf []AddressConfig, graphConf []GraphsConfig, hooks GraphHooks) inlet_http.GraphProvider {
+func NewAPIGraphProvider(apiHeader string, path string, addressConf []AddressConfig, graphConf []GraphsConfig, hooks GraphHooks, ipFilterConf IPFilterConfig) *APIGraphProvider {
 	mapAddr := make(map[string]spirit.MessageAddress)
 	for _, addr := range addressConf {
 		addr.Name = strings.TrimSpace(addr.Name)
@@ -47,6 +51,13 @@ func NewAPIGraphProvider(apiHeader string, path string, addressConf []AddressCon
 
 	apiGraph := make(map[string]spirit.MessageGraph)
 
+	globalIPFilter, e := newIPFilter(ipFilterConf)
+	if e != nil {
+		panic(fmt.Sprintf("bad global ip filter, error: %s", e))
+	}
+
+	apiIPFilters := make(map[string]*ipFilter)
+
 	for _, graph := range graphConf {
 		if apiName, exist := apiGraph[graph.API]; exist {
 			panic(fmt.Sprintf("api address already exist,api name: %s", apiName))
@@ -92,6 +103,12 @@ func NewAPIGraphProvider(apiHeader string, path string, addressConf []AddressCon
 			}
 
 			apiGraph[graph.API] = g
+
+			if filter, e := newIPFilter(graph.IPFilter); e != nil {
+				panic(fmt.Sprintf("bad ip filter of api %s, error: %s", graph.API, e))
+			} else if !filter.isEmpty() {
+				apiIPFilters[graph.API] = filter.merge(globalIPFilter)
+			}
 		}
 	}
 
@@ -101,17 +118,55 @@ func NewAPIGraphProvider(apiHeader string, path string, addressConf []AddressCon
 	}
 
 	return &APIGraphProvider{
-		APIHeader: apiHeader,
-		apiGraph:  apiGraph,
-		Path:      path,
+		APIHeader:    apiHeader,
+		apiGraph:     apiGraph,
+		Path:         path,
+		ipFilter:     globalIPFilter,
+		apiIPFilters: apiIPFilters,
 	}
 }
 
+func (p *APIGraphProvider) IsIPAllowed(apiName string, ip net.IP) bool {
+	if filter, exist := p.apiIPFilters[apiName]; exist {
+		return filter.Allowed(ip)
+	}
+	return p.ipFilter.Allowed(ip)
+}
+
 func (p *APIGraphProvider) SetGraph(apiName string, graph spirit.MessageGraph) inlet_http.GraphProvider {
 	p.apiGraph[apiName] = graph
 	return p
 }
 
+func (p *APIGraphProvider) Resolve(r *http.Request, apiName string) (graph spirit.MessageGraph, err error) {
+	apiName = strings.TrimSpace(apiName)
+
+	if apiName == "" {
+		err = ERR_API_NAME_IS_EMPTY.New()
+		return
+	}
+
+	exist := false
+	if graph, exist = p.apiGraph[apiName]; !exist {
+		err = ERR_API_GRAPH_IS_NOT_EXIST.New(errors.Params{"api": apiName})
+		return
+	}
+
+	if clientIP := conf.HTTP.ClientIP(r); !p.IsIPAllowed(apiName, clientIP) {
+		graph = nil
+		err = ERR_API_IP_BLOCKED.New(errors.Params{"api": apiName, "ip": clientIP})
+		logs.Warn("blocked api call, api:", apiName, "ip:", clientIP, "remote:", r.RemoteAddr, "request id:", requestId(r))
+		return
+	}
+
+	return
+}
+
+func (p *APIGraphProvider) IsAPIExist(apiName string) bool {
+	_, exist := p.apiGraph[apiName]
+	return exist
+}
+
 func (p *APIGraphProvider) GetGraph(r *http.Request, body []byte) (graphs map[string]spirit.MessageGraph, err error) {
 	if r.Method != METHOD_POST {
 		err = ERR_METHOD_IS_NOT_POST.New(errors.Params{"method": r.Method})
@@ -121,36 +176,31 @@ func (p *APIGraphProvider) GetGraph(r *http.Request, body []byte) (graphs map[st
 	apiGraphs := map[string]spirit.MessageGraph{}
 
 	appendFunc := func(apiName string) (err error) {
-		apiName = strings.TrimSpace(apiName)
-
-		if apiName == "" {
-			err = ERR_API_NAME_IS_EMPTY.New()
+		var apiGraph spirit.MessageGraph
+		if apiGraph, err = p.Resolve(r, apiName); err != nil {
 			return
 		}
 
-		if apiGraph, exist := p.apiGraph[apiName]; !exist {
-			err = ERR_API_GRAPH_IS_NOT_EXIST.New(errors.Params{"api": apiName})
+		if address, allowed := circuitBreakers.Allow(strings.TrimSpace(apiName)); !allowed {
+			err = ERR_API_CIRCUIT_OPEN.New(errors.Params{"api": apiName, "address": address})
 			return
-		} else {
-			apiGraphs[apiName] = apiGraph
 		}
+
+		apiGraphs[strings.TrimSpace(apiName)] = apiGraph
 		return
 	}
 
-	if r.Header.Get(MULTI_CALL) == "1" {
-		apiParams := map[string]interface{}{}
-		if e := json.Unmarshal(body, &apiParams); e != nil {
-			err = ERR_UNMARSHAL_MULTI_REQUEST_BODY_FAILED.New(errors.Params{"err": e})
-		} else if len(apiParams) > 0 {
-			for apiName, _ := range apiParams {
-				if err = appendFunc(apiName); err != nil {
-					return
-				}
-			}
-		} else {
-			err = ERR_EMPTY_MULTI_API_REQUEST.New()
+	if isMultiCall(r) {
+		var calls []APICall
+		if calls, _, err = parseMultiCall(body); err != nil {
 			return
 		}
+
+		for _, call := range calls {
+			if err = appendFunc(call.API); err != nil {
+				return
+			}
+		}
 	} else {
 		apiName := r.Header.Get(p.APIHeader)
 		apiName = strings.TrimSpace(apiName)
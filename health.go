@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gogap/spirit"
+)
+
+const (
+	DEFAULT_HEALTH_CALLBACK_THRESHOLD = 60
+)
+
+type HealthCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type HealthStatus struct {
+	Status   string                 `json:"status"`
+	Uptime   string                 `json:"uptime"`
+	Checks   map[string]HealthCheck `json:"checks,omitempty"`
+	Circuits map[string]string      `json:"circuits,omitempty"`
+}
+
+type HealthMonitor struct {
+	sync.Mutex
+
+	startTime time.Time
+
+	configLoaded  bool
+	rendererReady bool
+
+	lastCallback time.Time
+	next         uint64
+	pending      map[uint64]time.Time
+}
+
+func NewHealthMonitor() *HealthMonitor {
+	return &HealthMonitor{
+		startTime: time.Now(),
+		pending:   make(map[uint64]time.Time),
+	}
+}
+
+func (p *HealthMonitor) ConfigLoaded() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.configLoaded = true
+}
+
+func (p *HealthMonitor) RendererReady() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.rendererReady = true
+}
+
+func (p *HealthMonitor) Sent() (id uint64) {
+	return p.sent(time.Now())
+}
+
+func (p *HealthMonitor) sent(now time.Time) uint64 {
+	p.Lock()
+	defer p.Unlock()
+
+	p.next++
+	p.pending[p.next] = now
+
+	return p.next
+}
+
+// Done is called once the api call is over, answered or timed out
+func (p *HealthMonitor) Done(id uint64) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.pending, id)
+}
+
+func (p *HealthMonitor) Received() {
+	p.received(time.Now())
+}
+
+func (p *HealthMonitor) received(now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.lastCallback = now
+}
+
+// Track wraps the inlet handler, so the call sent by its payload hook stops
+// waiting when the handler returns
+func (p *HealthMonitor) Track(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if ctx := apiRequestContext(r); ctx != nil && ctx.CallbackWait > 0 {
+				p.Done(ctx.CallbackWait)
+				ctx.CallbackWait = 0
+			}
+		}()
+
+		handler(w, r)
+	}
+}
+
+func (p *HealthMonitor) Callback(handler spirit.ComponentHandler) spirit.ComponentHandler {
+	return func(payload *spirit.Payload) (interface{}, error) {
+		p.Received()
+		return handler(payload)
+	}
+}
+
+func (p *HealthMonitor) Health() HealthStatus {
+	return HealthStatus{
+		Status: "ok",
+		Uptime: time.Now().Sub(p.startTime).String(),
+	}
+}
+
+func (p *HealthMonitor) Readiness(threshold time.Duration) (status HealthStatus, ready bool) {
+	return p.readiness(time.Now(), threshold)
+}
+
+func (p *HealthMonitor) readiness(now time.Time, threshold time.Duration) (status HealthStatus, ready bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	status = HealthStatus{
+		Uptime: now.Sub(p.startTime).String(),
+		Checks: map[string]HealthCheck{
+			"config":   {OK: p.configLoaded},
+			"renderer": {OK: p.rendererReady},
+			"draining": {OK: !drainTracker.Draining()},
+		},
+		Circuits: circuitBreakers.States(),
+	}
+
+	callback := HealthCheck{OK: true}
+	if !p.lastCallback.IsZero() {
+		callback.Detail = fmt.Sprintf("last callback received %s ago", now.Sub(p.lastCallback))
+	}
+
+	var oldest time.Time
+	for _, sentAt := range p.pending {
+		if oldest.IsZero() || sentAt.Before(oldest) {
+			oldest = sentAt
+		}
+	}
+
+	if !oldest.IsZero() {
+		since := oldest
+		if p.lastCallback.After(since) {
+			since = p.lastCallback
+		}
+
+		if now.Sub(since) > threshold {
+			callback.OK = false
+			callback.Detail = fmt.Sprintf("no callback received for %s while api calls are pending", now.Sub(since))
+		}
+	}
+	status.Checks["callback"] = callback
+
+	ready = true
+	for _, check := range status.Checks {
+		ready = ready && check.OK
+	}
+
+	if status.Status = "ok"; !ready {
+		status.Status = "unavailable"
+	}
+
+	return
+}
+
+func healthzHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	writeResponse(healthMonitor.Health(), w, r)
+}
+
+func readyzHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+
+	status, ready := healthMonitor.Readiness(time.Duration(conf.HTTP.Health.CallbackThreshold) * time.Second)
+	if !ready {
+		writeResponseWithStatusCode(status, w, r, http.StatusServiceUnavailable)
+		return
+	}
+
+	writeResponse(status, w, r)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthMonitorReadiness(t *testing.T) {
+	threshold := time.Minute
+	start := time.Unix(1000000, 0)
+
+	type step struct {
+		at    time.Duration
+		op    string
+		call  int
+		ready bool
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "no traffic",
+			steps: []step{
+				{at: time.Hour, op: "check", ready: true},
+			},
+		},
+		{
+			name: "pending within threshold",
+			steps: []step{
+				{at: 0, op: "sent", call: 1},
+				{at: 59 * time.Second, op: "check", ready: true},
+			},
+		},
+		{
+			name: "pending past threshold without callback",
+			steps: []step{
+				{at: 0, op: "sent", call: 1},
+				{at: 61 * time.Second, op: "check", ready: false},
+				{at: 62 * time.Second, op: "received"},
+				{at: 62 * time.Second, op: "check", ready: true},
+			},
+		},
+		{
+			name: "timed out call stops waiting",
+			steps: []step{
+				{at: 0, op: "sent", call: 1},
+				{at: 30 * time.Second, op: "done", call: 1},
+				{at: time.Hour, op: "check", ready: true},
+			},
+		},
+		{
+			name: "answered calls leave the oldest pending one",
+			steps: []step{
+				{at: 0, op: "sent", call: 1},
+				{at: 10 * time.Second, op: "sent", call: 2},
+				{at: 20 * time.Second, op: "received"},
+				{at: 20 * time.Second, op: "done", call: 2},
+				{at: 70 * time.Second, op: "check", ready: true},
+				{at: 81 * time.Second, op: "check", ready: false},
+				{at: 90 * time.Second, op: "done", call: 1},
+				{at: 90 * time.Second, op: "check", ready: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		monitor := NewHealthMonitor()
+		monitor.ConfigLoaded()
+		monitor.RendererReady()
+
+		ids := map[int]uint64{}
+		for i, s := range test.steps {
+			now := start.Add(s.at)
+
+			switch s.op {
+			case "sent":
+				ids[s.call] = monitor.sent(now)
+			case "done":
+				monitor.Done(ids[s.call])
+			case "received":
+				monitor.received(now)
+			case "check":
+				if _, ready := monitor.readiness(now, threshold); ready != s.ready {
+					t.Errorf("%s: step %d ready = %v, want %v", test.name, i, ready, s.ready)
+				}
+			}
+		}
+	}
+}
+
+func TestHealthMonitorReadinessChecks(t *testing.T) {
+	monitor := NewHealthMonitor()
+
+	if _, ready := monitor.Readiness(time.Minute); ready {
+		t.Errorf("Readiness before the config is loaded = true, want false")
+	}
+
+	monitor.ConfigLoaded()
+	if _, ready := monitor.Readiness(time.Minute); ready {
+		t.Errorf("Readiness before the renderer is ready = true, want false")
+	}
+
+	monitor.RendererReady()
+	if _, ready := monitor.Readiness(time.Minute); !ready {
+		t.Errorf("Readiness = false, want true")
+	}
+}
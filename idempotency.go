@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogap/errors"
+)
+
+const (
+	DEFAULT_IDEMPOTENCY_HEADER           = "Idempotency-Key"
+	DEFAULT_IDEMPOTENCY_PRINCIPAL_HEADER = "Authorization"
+	DEFAULT_IDEMPOTENCY_TTL              = 86400
+
+	IDEMPOTENCY_REPLAYED_HEADER = "Idempotent-Replayed"
+	MAX_IDEMPOTENCY_KEY_LENGTH  = 255
+	IDEMPOTENCY_EXPIRE_INTERVAL = time.Minute
+)
+
+type idempotencyEntry struct {
+	bodyHash  string
+	done      bool
+	status    int
+	text      string
+	response  APIResponse
+	expiresAt time.Time
+}
+
+type IdempotencyStore struct {
+	sync.Mutex
+
+	ttl     time.Duration
+	entries map[string]*idempotencyEntry
+}
+
+func NewIdempotencyStore(idempotencyConf IdempotencyConfig) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:     time.Duration(idempotencyConf.TTL) * time.Second,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+func (p *IdempotencyStore) Reserve(key, bodyHash string) (entry idempotencyEntry, reserved bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	if exist, ok := p.entries[key]; ok && now.Before(exist.expiresAt) {
+		return *exist, false
+	}
+
+	p.entries[key] = &idempotencyEntry{bodyHash: bodyHash, expiresAt: now.Add(p.ttl)}
+
+	return idempotencyEntry{}, true
+}
+
+func (p *IdempotencyStore) Complete(key string, status int, text string, resp APIResponse) {
+	p.Lock()
+	defer p.Unlock()
+
+	if entry, ok := p.entries[key]; ok {
+		entry.done = true
+		entry.status = status
+		entry.text = text
+		entry.response = resp
+		entry.expiresAt = time.Now().Add(p.ttl)
+	}
+}
+
+func (p *IdempotencyStore) Release(key string) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.entries, key)
+}
+
+func (p *IdempotencyStore) Expire(now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+
+	for key, entry := range p.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(p.entries, key)
+		}
+	}
+}
+
+func isIdempotentCall(r *http.Request) bool {
+	if idempotencyStore == nil || isMultiCall(r) || !idempotentAPI[requestAPIName(r)] {
+		return false
+	}
+
+	_, exist := r.Header[http.CanonicalHeaderKey(conf.HTTP.Idempotency.Header)]
+	return exist
+}
+
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > MAX_IDEMPOTENCY_KEY_LENGTH {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+func idempotencyPrincipal(r *http.Request) string {
+	if subject := clientCertSubject(r); subject != "" {
+		return "cert:" + subject
+	}
+	if principal := r.Header.Get(conf.HTTP.Idempotency.PrincipalHeader); principal != "" {
+		return "h:" + principal
+	}
+	return "ip:" + conf.HTTP.ClientIP(r).String()
+}
+
+func sha256Hex(values ...string) string {
+	hash := sha256.New()
+	for _, value := range values {
+		hash.Write([]byte(value))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func idempotentCallHandle(w http.ResponseWriter, r *http.Request, handler func(w http.ResponseWriter, r *http.Request)) {
+	apiName := requestAPIName(r)
+	key := strings.TrimSpace(r.Header.Get(conf.HTTP.Idempotency.Header))
+
+	if !validIdempotencyKey(key) {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_IDEMPOTENCY_KEY_INVALID.New(errors.Params{"api": apiName})), w, r, http.StatusBadRequest)
+		return
+	}
+
+	body, e := readRequestBody(r)
+	if e != nil {
+		errorResponseHandler(e, w, r)
+		return
+	}
+
+	storeKey := sha256Hex(idempotencyPrincipal(r), apiName, key)
+	bodyHash := sha256Hex(string(body))
+
+	entry, reserved := idempotencyStore.Reserve(storeKey, bodyHash)
+	if !reserved {
+		switch {
+		case entry.bodyHash != bodyHash:
+			writeResponseWithStatusCode(newErrorAPIResponse(ERR_IDEMPOTENCY_KEY_REUSED.New(errors.Params{"api": apiName})), w, r, http.StatusUnprocessableEntity)
+		case !entry.done:
+			writeResponseWithStatusCode(newErrorAPIResponse(ERR_IDEMPOTENCY_IN_PROGRESS.New(errors.Params{"api": apiName})), w, r, http.StatusConflict)
+		default:
+			if ctx := apiRequestContext(r); ctx != nil {
+				ctx.Responses = map[string]APIResponse{apiName: entry.response}
+			}
+			w.Header().Set(IDEMPOTENCY_REPLAYED_HEADER, "true")
+			writeTextResponseWithStatusCode(entry.text, w, r, entry.status)
+		}
+		return
+	}
+
+	completed := false
+	defer func() {
+		if !completed {
+			idempotencyStore.Release(storeKey)
+		}
+	}()
+
+	handler(w, r)
+
+	if ctx := apiRequestContext(r); ctx != nil {
+		if resp, exist := ctx.Responses[apiName]; exist && resp.Code == 0 && ctx.Rendered != "" {
+			idempotencyStore.Complete(storeKey, ctx.RenderedStatus, ctx.Rendered, resp)
+			completed = true
+		}
+	}
+}
+
+func expireIdempotencyKeys() {
+	ticker := time.NewTicker(IDEMPOTENCY_EXPIRE_INTERVAL)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		idempotencyStore.Expire(now)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{key: "a", valid: true},
+		{key: "0f8fad5b-d9cb-469f-a165-70867728950e", valid: true},
+		{key: "order 42/retry=1", valid: true},
+		{key: strings.Repeat("k", MAX_IDEMPOTENCY_KEY_LENGTH), valid: true},
+
+		{key: "", valid: false},
+		{key: strings.Repeat("k", MAX_IDEMPOTENCY_KEY_LENGTH+1), valid: false},
+		{key: "a\tb", valid: false},
+		{key: "a\nb", valid: false},
+		{key: "ключ", valid: false},
+	}
+
+	for _, test := range tests {
+		if valid := validIdempotencyKey(test.key); valid != test.valid {
+			t.Errorf("validIdempotencyKey(%q) = %v, want %v", test.key, valid, test.valid)
+		}
+	}
+}
+
+func TestIsIdempotentCall(t *testing.T) {
+	defer func(store *IdempotencyStore, apis map[string]bool, httpConf HTTPConfig) {
+		idempotencyStore, idempotentAPI, conf.HTTP = store, apis, httpConf
+	}(idempotencyStore, idempotentAPI, conf.HTTP)
+
+	idempotencyStore = NewIdempotencyStore(IdempotencyConfig{TTL: 60})
+	idempotentAPI = map[string]bool{"api.order.create": true}
+	conf.HTTP.APIHeader = "X-Api"
+	conf.HTTP.PATH = "/v1"
+	conf.HTTP.Idempotency.Header = DEFAULT_IDEMPOTENCY_HEADER
+
+	tests := []struct {
+		api        string
+		headers    map[string]string
+		idempotent bool
+	}{
+		{api: "api.order.create", headers: map[string]string{"Idempotency-Key": "k"}, idempotent: true},
+		{api: "api.order.create", headers: map[string]string{"idempotency-key": "k"}, idempotent: true},
+		{api: "api.order.create", headers: map[string]string{"Idempotency-Key": "  "}, idempotent: true},
+		{api: "api.order.create", headers: map[string]string{"Idempotency-Key": ""}, idempotent: true},
+		{api: "api.order.create", idempotent: false},
+		{api: "api.order.list", headers: map[string]string{"Idempotency-Key": "k"}, idempotent: false},
+		{api: "api.order.create", headers: map[string]string{"Idempotency-Key": "k", MULTI_CALL: "1"}, idempotent: false},
+	}
+
+	for _, test := range tests {
+		r := &http.Request{URL: &url.URL{Path: "/v1"}, Header: http.Header{}}
+		r.Header.Set("X-Api", test.api)
+		for key, value := range test.headers {
+			r.Header.Set(key, value)
+		}
+
+		if idempotent := isIdempotentCall(r); idempotent != test.idempotent {
+			t.Errorf("isIdempotentCall(%s, %v) = %v, want %v", test.api, test.headers, idempotent, test.idempotent)
+		}
+	}
+}
+
+func TestIdempotencyStoreKey(t *testing.T) {
+	base := sha256Hex("h:token", "api.order.create", "k")
+
+	tests := []struct {
+		values []string
+		same   bool
+	}{
+		{values: []string{"h:token", "api.order.create", "k"}, same: true},
+		{values: []string{"h:other", "api.order.create", "k"}, same: false},
+		{values: []string{"h:token", "api.order.cancel", "k"}, same: false},
+		{values: []string{"h:token", "api.order.create", "k2"}, same: false},
+		{values: []string{"h:tokenapi.order.create", "", "k"}, same: false},
+		{values: []string{"h:token", "api.order.createk", ""}, same: false},
+	}
+
+	for _, test := range tests {
+		if same := sha256Hex(test.values...) == base; same != test.same {
+			t.Errorf("sha256Hex(%q) equal to the base key = %v, want %v", test.values, same, test.same)
+		}
+	}
+}
+
+func TestIdempotencyStoreReserve(t *testing.T) {
+	store := NewIdempotencyStore(IdempotencyConfig{TTL: 60})
+
+	if _, reserved := store.Reserve("key", "body"); !reserved {
+		t.Fatal("first reserve should succeed")
+	}
+
+	if entry, reserved := store.Reserve("key", "body"); reserved || entry.done {
+		t.Errorf("reserve of an in-progress key = %v, done %v, want false, false", reserved, entry.done)
+	}
+
+	store.Complete("key", http.StatusOK, "text", APIResponse{})
+	if entry, reserved := store.Reserve("key", "other"); reserved || !entry.done || entry.bodyHash != "body" || entry.text != "text" {
+		t.Errorf("reserve of a completed key = %v, %+v", reserved, entry)
+	}
+
+	store.Release("key")
+	if _, reserved := store.Reserve("key", "body"); !reserved {
+		t.Error("reserve after release should succeed")
+	}
+}
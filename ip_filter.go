@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net"
+)
+
+type ipFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func newIPFilter(filterConf IPFilterConfig) (filter *ipFilter, err error) {
+	filter = &ipFilter{}
+
+	if filter.allow, err = parseCIDRs(filterConf.Allow); err != nil {
+		return
+	}
+
+	if filter.deny, err = parseCIDRs(filterConf.Deny); err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *ipFilter) isEmpty() bool {
+	return len(p.allow) == 0 && len(p.deny) == 0
+}
+
+func (p *ipFilter) merge(parent *ipFilter) *ipFilter {
+	merged := &ipFilter{
+		allow: p.allow,
+		deny:  append(append([]*net.IPNet{}, parent.deny...), p.deny...),
+	}
+
+	if len(merged.allow) == 0 {
+		merged.allow = parent.allow
+	}
+
+	return merged
+}
+
+func (p *ipFilter) Allowed(ip net.IP) bool {
+	if p == nil {
+		return true
+	}
+
+	if containsIP(p.deny, ip) {
+		return false
+	}
+
+	if len(p.allow) > 0 {
+		return containsIP(p.allow, ip)
+	}
+
+	return true
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPFilterMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  IPFilterConfig
+		api     IPFilterConfig
+		ip      string
+		allowed bool
+	}{
+		{name: "no rules", ip: "1.2.3.4", allowed: true},
+		{name: "global allow", global: IPFilterConfig{Allow: []string{"10.0.0.0/8"}}, ip: "10.1.1.1", allowed: true},
+		{name: "global allow miss", global: IPFilterConfig{Allow: []string{"10.0.0.0/8"}}, ip: "1.2.3.4", allowed: false},
+		{name: "global deny", global: IPFilterConfig{Deny: []string{"1.2.3.4"}}, ip: "1.2.3.4", allowed: false},
+		{name: "deny wins over allow", global: IPFilterConfig{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1"}}, ip: "10.0.0.1", allowed: false},
+		{name: "api allow replaces global allow", global: IPFilterConfig{Allow: []string{"10.0.0.0/8"}}, api: IPFilterConfig{Allow: []string{"192.168.0.0/16"}}, ip: "10.1.1.1", allowed: false},
+		{name: "api allow", global: IPFilterConfig{Allow: []string{"10.0.0.0/8"}}, api: IPFilterConfig{Allow: []string{"192.168.0.0/16"}}, ip: "192.168.1.1", allowed: true},
+		{name: "api inherits global allow", global: IPFilterConfig{Allow: []string{"10.0.0.0/8"}}, api: IPFilterConfig{Deny: []string{"10.0.0.1"}}, ip: "1.2.3.4", allowed: false},
+		{name: "api deny adds to global deny", global: IPFilterConfig{Deny: []string{"1.1.1.1"}}, api: IPFilterConfig{Deny: []string{"2.2.2.2"}}, ip: "2.2.2.2", allowed: false},
+		{name: "global deny kept", global: IPFilterConfig{Deny: []string{"1.1.1.1"}}, api: IPFilterConfig{Deny: []string{"2.2.2.2"}}, ip: "1.1.1.1", allowed: false},
+		{name: "api allow cannot lift global deny", global: IPFilterConfig{Deny: []string{"1.1.1.1"}}, api: IPFilterConfig{Allow: []string{"1.1.1.1"}}, ip: "1.1.1.1", allowed: false},
+		{name: "ipv6", global: IPFilterConfig{Allow: []string{"fd00::/8"}}, ip: "fd00::1", allowed: true},
+		{name: "nil ip", global: IPFilterConfig{Allow: []string{"10.0.0.0/8"}}, ip: "", allowed: false},
+	}
+
+	for _, test := range tests {
+		global, err := newIPFilter(test.global)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		api, err := newIPFilter(test.api)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if allowed := api.merge(global).Allowed(net.ParseIP(test.ip)); allowed != test.allowed {
+			t.Errorf("%s: Allowed(%q) = %v, want %v", test.name, test.ip, allowed, test.allowed)
+		}
+	}
+}
+
+func TestNilIPFilter(t *testing.T) {
+	var filter *ipFilter
+	if !filter.Allowed(net.ParseIP("1.2.3.4")) {
+		t.Error("nil filter should allow every ip")
+	}
+}
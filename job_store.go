@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogap/logs"
+)
+
+const (
+	JOB_STATUS_PENDING = "pending"
+	JOB_STATUS_DONE    = "done"
+
+	JOB_STORE_MEMORY = "memory"
+	JOB_STORE_FILE   = "file"
+
+	JOB_FILE_QUARANTINE_SUFFIX = ".bad"
+)
+
+var (
+	jobIdRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+type AsyncJob struct {
+	Id         string       `json:"id"`
+	API        string       `json:"api"`
+	RequestId  string       `json:"request_id"`
+	Callback   string       `json:"callback,omitempty"`
+	Status     string       `json:"status"`
+	CreatedAt  time.Time    `json:"created_at"`
+	FinishedAt time.Time    `json:"finished_at,omitempty"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	Response   *APIResponse `json:"response,omitempty"`
+}
+
+func (p *AsyncJob) Expired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && now.After(p.ExpiresAt)
+}
+
+type JobStore interface {
+	Put(job *AsyncJob) error
+	Get(id string) (*AsyncJob, error)
+	Delete(id string) error
+	Expire(now time.Time) error
+}
+
+func NewJobStore(asyncConf AsyncConfig) (store JobStore, err error) {
+	switch strings.ToLower(strings.TrimSpace(asyncConf.Store)) {
+	case "", JOB_STORE_MEMORY:
+		store = newMemoryJobStore()
+	case JOB_STORE_FILE:
+		store, err = newFileJobStore(asyncConf.Dir)
+	default:
+		err = fmt.Errorf("unknown async job store: %s", asyncConf.Store)
+	}
+	return
+}
+
+type memoryJobStore struct {
+	sync.Mutex
+
+	jobs map[string]AsyncJob
+}
+
+func newMemoryJobStore() *memoryJobStore {
+	return &memoryJobStore{jobs: make(map[string]AsyncJob)}
+}
+
+func (p *memoryJobStore) Put(job *AsyncJob) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.jobs[job.Id] = *job
+	return nil
+}
+
+func (p *memoryJobStore) Get(id string) (*AsyncJob, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if job, exist := p.jobs[id]; exist {
+		return &job, nil
+	}
+	return nil, nil
+}
+
+func (p *memoryJobStore) Delete(id string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.jobs, id)
+	return nil
+}
+
+func (p *memoryJobStore) Expire(now time.Time) error {
+	p.Lock()
+	defer p.Unlock()
+
+	for id, job := range p.jobs {
+		if job.Expired(now) {
+			delete(p.jobs, id)
+		}
+	}
+	return nil
+}
+
+type fileJobStore struct {
+	dir string
+}
+
+func newFileJobStore(dir string) (store *fileJobStore, err error) {
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+
+	store = &fileJobStore{dir: dir}
+	return
+}
+
+func (p *fileJobStore) filename(id string) (string, error) {
+	if !jobIdRegexp.MatchString(id) {
+		return "", fmt.Errorf("bad job id: %q", id)
+	}
+	return filepath.Join(p.dir, id+".json"), nil
+}
+
+func (p *fileJobStore) Put(job *AsyncJob) (err error) {
+	var filename string
+	if filename, err = p.filename(job.Id); err != nil {
+		return
+	}
+
+	var data []byte
+	if data, err = json.Marshal(job); err != nil {
+		return
+	}
+
+	tmpFile := filename + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		return
+	}
+
+	return os.Rename(tmpFile, filename)
+}
+
+func (p *fileJobStore) Get(id string) (job *AsyncJob, err error) {
+	filename, e := p.filename(id)
+	if e != nil {
+		return
+	}
+
+	data, e := ioutil.ReadFile(filename)
+	if os.IsNotExist(e) {
+		return
+	} else if e != nil {
+		err = e
+		return
+	}
+
+	job = new(AsyncJob)
+	if err = json.Unmarshal(data, job); err != nil {
+		job = nil
+	}
+	return
+}
+
+func (p *fileJobStore) Delete(id string) (err error) {
+	var filename string
+	if filename, err = p.filename(id); err != nil {
+		return
+	}
+
+	if err = os.Remove(filename); os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+func (p *fileJobStore) Expire(now time.Time) (err error) {
+	var matches []string
+	if matches, err = filepath.Glob(filepath.Join(p.dir, "*.json")); err != nil {
+		return
+	}
+
+	for _, file := range matches {
+		data, e := ioutil.ReadFile(file)
+		if e != nil {
+			continue
+		}
+
+		job := AsyncJob{}
+		if e = json.Unmarshal(data, &job); e != nil {
+			logs.Warn("quarantine undecodable async job file", file, "error:", e)
+			os.Rename(file, file+JOB_FILE_QUARANTINE_SUFFIX)
+			continue
+		}
+
+		if job.Expired(now) {
+			os.Remove(file)
+		}
+	}
+	return
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	JSONRPC_VERSION = "2.0"
+
+	DEFAULT_JSONRPC_PATH           = "/jsonrpc"
+	DEFAULT_JSONRPC_MAX_BATCH_SIZE = 20
+
+	JSONRPC_PARSE_ERROR      = -32700
+	JSONRPC_INVALID_REQUEST  = -32600
+	JSONRPC_METHOD_NOT_FOUND = -32601
+	JSONRPC_INVALID_PARAMS   = -32602
+	JSONRPC_SERVER_ERROR     = -32000
+)
+
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type jsonRPCCall struct {
+	Id     json.RawMessage
+	Notify bool
+	Method string
+	Params interface{}
+}
+
+func jsonRPCErrorResponse(id json.RawMessage, err JSONRPCError) map[string]interface{} {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	return map[string]interface{}{
+		"jsonrpc": JSONRPC_VERSION,
+		"id":      id,
+		"error":   err,
+	}
+}
+
+func jsonRPCResponse(id json.RawMessage, resp APIResponse) map[string]interface{} {
+	if resp.Code != 0 {
+		return jsonRPCErrorResponse(id, JSONRPCError{
+			Code:    JSONRPC_SERVER_ERROR,
+			Message: resp.Message,
+			Data: map[string]interface{}{
+				"code":            resp.Code,
+				"error_id":        resp.ErrorId,
+				"error_namespace": resp.ErrorNamespace,
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"jsonrpc": JSONRPC_VERSION,
+		"id":      id,
+		"result":  resp.Result,
+	}
+}
+
+func parseJSONRPCCall(raw json.RawMessage) (call jsonRPCCall, errResp map[string]interface{}) {
+	fields := map[string]json.RawMessage{}
+	if e := json.Unmarshal(raw, &fields); e != nil {
+		errResp = jsonRPCErrorResponse(nil, JSONRPCError{Code: JSONRPC_INVALID_REQUEST, Message: "invalid request"})
+		return
+	}
+
+	id, hasId := fields["id"]
+	call.Notify = !hasId
+
+	if hasId {
+		var v interface{}
+		if e := json.Unmarshal(id, &v); e != nil {
+			errResp = jsonRPCErrorResponse(nil, JSONRPCError{Code: JSONRPC_INVALID_REQUEST, Message: "invalid request id"})
+			return
+		}
+
+		switch v.(type) {
+		case nil, string, float64:
+			call.Id = id
+		default:
+			errResp = jsonRPCErrorResponse(nil, JSONRPCError{Code: JSONRPC_INVALID_REQUEST, Message: "invalid request id"})
+			return
+		}
+	}
+
+	var version string
+	if e := json.Unmarshal(fields["jsonrpc"], &version); e != nil || version != JSONRPC_VERSION {
+		errResp = jsonRPCErrorResponse(call.Id, JSONRPCError{Code: JSONRPC_INVALID_REQUEST, Message: "jsonrpc version should be 2.0"})
+		return
+	}
+
+	if e := json.Unmarshal(fields["method"], &call.Method); e != nil || strings.TrimSpace(call.Method) == "" {
+		errResp = jsonRPCErrorResponse(call.Id, JSONRPCError{Code: JSONRPC_INVALID_REQUEST, Message: "method should be a non-empty string"})
+		return
+	}
+	call.Method = strings.TrimSpace(call.Method)
+
+	if params, exist := fields["params"]; exist {
+		decoder := json.NewDecoder(bytes.NewReader(params))
+		decoder.UseNumber()
+		if e := decoder.Decode(&call.Params); e != nil {
+			errResp = jsonRPCErrorResponse(call.Id, JSONRPCError{Code: JSONRPC_INVALID_PARAMS, Message: "invalid params"})
+			return
+		}
+
+		switch call.Params.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			errResp = jsonRPCErrorResponse(call.Id, JSONRPCError{Code: JSONRPC_INVALID_PARAMS, Message: "params should be an object or an array"})
+			return
+		}
+	}
+
+	return
+}
+
+func jsonRPCHandle(w http.ResponseWriter, r *http.Request) {
+	body, e := readRequestBody(r)
+	body = bytes.TrimSpace(body)
+
+	var raws []json.RawMessage
+
+	isBatch := len(body) > 0 && body[0] == '['
+	if e == nil {
+		if isBatch {
+			e = json.Unmarshal(body, &raws)
+		} else {
+			var raw json.RawMessage
+			if e = json.Unmarshal(body, &raw); e == nil {
+				raws = append(raws, raw)
+			}
+		}
+	}
+
+	if e != nil || len(body) == 0 {
+		writeResponse(jsonRPCErrorResponse(nil, JSONRPCError{Code: JSONRPC_PARSE_ERROR, Message: "parse error"}), w, r)
+		return
+	}
+
+	if len(raws) == 0 {
+		writeResponse(jsonRPCErrorResponse(nil, JSONRPCError{Code: JSONRPC_INVALID_REQUEST, Message: "empty batch"}), w, r)
+		return
+	}
+
+	if maxSize := conf.HTTP.JSONRPC.MaxBatchSize; len(raws) > maxSize {
+		writeResponse(jsonRPCErrorResponse(nil, JSONRPCError{Code: JSONRPC_INVALID_REQUEST, Message: "batch too large, max: " + strconv.Itoa(maxSize)}), w, r)
+		return
+	}
+
+	timeout := parseTimeoutHeader(r, API_CALL_TIMEOUT)
+
+	outputs := make([]map[string]interface{}, len(raws))
+	ids := map[string]json.RawMessage{}
+	methods := map[string]string{}
+	pending := []APICall{}
+	notifications := []APICall{}
+
+	for i, raw := range raws {
+		call, errResp := parseJSONRPCCall(raw)
+		if errResp != nil {
+			if !call.Notify || call.Method == "" {
+				outputs[i] = errResp
+			}
+			continue
+		}
+
+		if !apiGraphProvider.IsAPIExist(call.Method) {
+			if !call.Notify {
+				outputs[i] = jsonRPCErrorResponse(call.Id, JSONRPCError{Code: JSONRPC_METHOD_NOT_FOUND, Message: "method not found"})
+			}
+			continue
+		}
+
+		if _, err := apiGraphProvider.Resolve(r, call.Method); err != nil {
+			if !call.Notify {
+				outputs[i] = jsonRPCResponse(call.Id, newErrorAPIResponse(err))
+			}
+			continue
+		}
+
+		name := strconv.Itoa(i)
+		apiCall := APICall{Name: name, API: call.Method, Content: call.Params, Timeout: effectiveTimeout(call.Method, timeout)}
+
+		if call.Notify {
+			notifications = append(notifications, apiCall)
+			continue
+		}
+
+		ids[name] = call.Id
+		methods[name] = call.Method
+		pending = append(pending, apiCall)
+	}
+
+	if len(notifications) > 0 {
+		detached := detachRequest(r, apiRequestContext(r))
+		for _, call := range notifications {
+			done := drainTracker.Track(call.API)
+			go func(call APICall) {
+				defer done()
+				apiDispatcher.Call(detached, call)
+			}(call)
+		}
+	}
+
+	writeEffectiveTimeout(w, longestCallTimeout(pending))
+
+	responses := map[string]APIResponse{}
+	for name, resp := range apiDispatcher.CallAll(r, pending) {
+		index, _ := strconv.Atoi(name)
+		outputs[index] = jsonRPCResponse(ids[name], resp)
+		responses[name] = resp
+	}
+
+	if ctx := apiRequestContext(r); ctx != nil {
+		ctx.Aliases = methods
+		ctx.Responses = responses
+	}
+
+	results := []map[string]interface{}{}
+	for _, output := range outputs {
+		if output != nil {
+			results = append(results, output)
+		}
+	}
+
+	if len(results) == 0 {
+		writeAccessHeaders(w, r)
+		writeBasicHeaders(w, r)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if !isBatch {
+		writeResponse(results[0], w, r)
+		return
+	}
+
+	writeResponse(results, w, r)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseJSONRPCCall(t *testing.T) {
+	tests := []struct {
+		raw    string
+		id     string
+		notify bool
+		method string
+		code   int
+	}{
+		{raw: `{"jsonrpc":"2.0","id":1,"method":"api.user.get","params":{"id":7}}`, id: "1", method: "api.user.get"},
+		{raw: `{"jsonrpc":"2.0","id":"a","method":" api.user.get ","params":[7]}`, id: `"a"`, method: "api.user.get"},
+		{raw: `{"jsonrpc":"2.0","id":null,"method":"api.user.get"}`, id: "null", method: "api.user.get"},
+		{raw: `{"jsonrpc":"2.0","method":"api.user.get"}`, notify: true, method: "api.user.get"},
+
+		{raw: `[]`, code: JSONRPC_INVALID_REQUEST},
+		{raw: `"call"`, code: JSONRPC_INVALID_REQUEST},
+		{raw: `{"jsonrpc":"2.0","id":{},"method":"api.user.get"}`, code: JSONRPC_INVALID_REQUEST},
+		{raw: `{"jsonrpc":"2.0","id":[1],"method":"api.user.get"}`, code: JSONRPC_INVALID_REQUEST},
+		{raw: `{"id":1,"method":"api.user.get"}`, id: "1", code: JSONRPC_INVALID_REQUEST},
+		{raw: `{"jsonrpc":"1.0","id":1,"method":"api.user.get"}`, id: "1", code: JSONRPC_INVALID_REQUEST},
+		{raw: `{"jsonrpc":"2.0","id":1}`, id: "1", code: JSONRPC_INVALID_REQUEST},
+		{raw: `{"jsonrpc":"2.0","id":1,"method":"  "}`, id: "1", method: "  ", code: JSONRPC_INVALID_REQUEST},
+		{raw: `{"jsonrpc":"2.0","id":1,"method":5}`, id: "1", code: JSONRPC_INVALID_REQUEST},
+		{raw: `{"jsonrpc":"2.0","id":1,"method":"api.user.get","params":7}`, id: "1", method: "api.user.get", code: JSONRPC_INVALID_PARAMS},
+		{raw: `{"jsonrpc":"2.0","id":1,"method":"api.user.get","params":"a"}`, id: "1", method: "api.user.get", code: JSONRPC_INVALID_PARAMS},
+		{raw: `{"jsonrpc":"2.0","method":"api.user.get","params":null}`, notify: true, method: "api.user.get", code: JSONRPC_INVALID_PARAMS},
+	}
+
+	for _, test := range tests {
+		call, errResp := parseJSONRPCCall(json.RawMessage(test.raw))
+
+		code := 0
+		if errResp != nil {
+			code = errResp["error"].(JSONRPCError).Code
+		}
+
+		if code != test.code || string(call.Id) != test.id || call.Notify != test.notify || call.Method != test.method {
+			t.Errorf("parseJSONRPCCall(%s) = id %s, notify %v, method %q, code %d, want id %s, notify %v, method %q, code %d",
+				test.raw, call.Id, call.Notify, call.Method, code, test.id, test.notify, test.method, test.code)
+		}
+	}
+}
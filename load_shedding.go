@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogap/errors"
+	"github.com/gogap/logs"
+)
+
+const (
+	DEFAULT_SHEDDING_MAX_INFLIGHT  = 1024
+	DEFAULT_SHEDDING_MAX_QUEUE     = 128
+	DEFAULT_SHEDDING_QUEUE_TIMEOUT = 100
+	DEFAULT_SHEDDING_RETRY_AFTER   = 1
+)
+
+type concurrencyLimiter struct {
+	slots    chan struct{}
+	waiting  int32
+	maxQueue int32
+}
+
+func newConcurrencyLimiter(maxInflight, maxQueue int) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		slots:    make(chan struct{}, maxInflight),
+		maxQueue: int32(maxQueue),
+	}
+}
+
+func (p *concurrencyLimiter) Acquire(timeout time.Duration) bool {
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if atomic.AddInt32(&p.waiting, 1) > p.maxQueue {
+		atomic.AddInt32(&p.waiting, -1)
+		return false
+	}
+	defer atomic.AddInt32(&p.waiting, -1)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (p *concurrencyLimiter) Release() {
+	<-p.slots
+}
+
+type LoadShedder struct {
+	global       *concurrencyLimiter
+	apis         map[string]*concurrencyLimiter
+	queueTimeout time.Duration
+}
+
+func NewLoadShedder(sheddingConf LoadSheddingConfig, graphConf []GraphsConfig) *LoadShedder {
+	shedder := &LoadShedder{
+		global:       newConcurrencyLimiter(sheddingConf.MaxInflight, sheddingConf.MaxQueue),
+		apis:         make(map[string]*concurrencyLimiter),
+		queueTimeout: time.Duration(sheddingConf.QueueTimeout) * time.Millisecond,
+	}
+
+	for _, graph := range graphConf {
+		if graph.MaxInflight > 0 {
+			shedder.apis[graph.API] = newConcurrencyLimiter(graph.MaxInflight, sheddingConf.MaxQueue)
+		}
+	}
+
+	return shedder
+}
+
+func (p *LoadShedder) AcquireGlobal() (release func(), ok bool) {
+	if !p.global.Acquire(p.queueTimeout) {
+		return nil, false
+	}
+	return p.global.Release, true
+}
+
+func (p *LoadShedder) AcquireAPI(apiName string) (release func(), ok bool) {
+	apiLimiter, exist := p.apis[apiName]
+	if !exist {
+		return func() {}, true
+	}
+
+	if !apiLimiter.Acquire(p.queueTimeout) {
+		return nil, false
+	}
+	return apiLimiter.Release, true
+}
+
+// detached calls outlive the request that held the global slot, so they take
+// one of their own
+func (p *LoadShedder) AcquireCall(parent *http.Request, apiName string) (release func(), ok bool) {
+	if !isDetached(parent) {
+		return p.AcquireAPI(apiName)
+	}
+
+	releaseGlobal, ok := p.AcquireGlobal()
+	if !ok {
+		return nil, false
+	}
+
+	releaseAPI, ok := p.AcquireAPI(apiName)
+	if !ok {
+		releaseGlobal()
+		return nil, false
+	}
+
+	release = func() {
+		releaseAPI()
+		releaseGlobal()
+	}
+
+	return release, true
+}
+
+func writeShedResponse(w http.ResponseWriter, r *http.Request, apiName string) {
+	logs.Warn("request shed, api:", apiName, "request id:", requestId(r))
+	recordShedStats(apiName)
+
+	w.Header().Set("Retry-After", strconv.Itoa(conf.HTTP.LoadShedding.RetryAfter))
+	writeResponseWithStatusCode(newErrorAPIResponse(ERR_API_OVERLOADED.New(errors.Params{"api": apiName})), w, r, http.StatusServiceUnavailable)
+}
+
+func loadSheddingHandle(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if loadShedder == nil {
+			handler(w, r)
+			return
+		}
+
+		release, ok := loadShedder.AcquireGlobal()
+		if !ok {
+			apiName := ""
+			if !isMultiCall(r) {
+				apiName = requestAPIName(r)
+			}
+			writeShedResponse(w, r, apiName)
+			return
+		}
+		defer release()
+
+		handler(w, r)
+	}
+}
+
+// the per api limits of direct calls, calls made through the dispatcher take
+// theirs in APIDispatcher.call
+func apiSheddingHandle(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if loadShedder == nil {
+			handler(w, r)
+			return
+		}
+
+		apiName := requestAPIName(r)
+		release, ok := loadShedder.AcquireAPI(apiName)
+		if !ok {
+			writeShedResponse(w, r, apiName)
+			return
+		}
+		defer release()
+
+		handler(w, r)
+	}
+}
@@ -23,18 +23,45 @@ const (
 	METHOD_OPTIONS = "OPTIONS"
 )
 
+const (
+	CTX_CLIENT_IP     = "client_ip"
+	CTX_CLIENT_SCHEME = "client_scheme"
+	CTX_CLIENT_HOST   = "client_host"
+	CTX_REQUEST_ID    = "request_id"
+	CTX_STREAM_ID     = "stream_id"
+)
+
 var (
 	conf InletHTTPAPIConfig
 
 	proxyAPI = make(map[string]bool)
-)
+	asyncAPI = make(map[string]bool)
 
-var (
-	responseRenderer *APIResponseRenderer
+	idempotentAPI = make(map[string]bool)
+	retryPolicies = make(map[string]*RetryPolicy)
+
+	timeoutPolicies = make(map[string]TimeoutPolicy)
 )
 
 var (
-	renderedXDomainProxy string
+	responseRenderer *APIResponseRenderer
+	statsCollector   *APIStatsCollector
+	accessLogger     *AccessLogger
+	redactor         *Redactor
+	corsPolicies     *CORSPolicies
+	apiGraphProvider *APIGraphProvider
+	apiDispatcher    *APIDispatcher
+	jobStore         JobStore
+	webhookAllowlist *WebhookAllowlist
+	webhookDeliverer *WebhookDeliverer
+	streamHub        *StreamHub
+	idempotencyStore *IdempotencyStore
+	circuitBreakers  *CircuitBreakers
+	loadShedder      *LoadShedder
+	tlsTerminator    *TLSTerminator
+
+	drainTracker  = NewDrainTracker()
+	healthMonitor = NewHealthMonitor()
 )
 
 func main() {
@@ -53,14 +80,24 @@ func main() {
 
 	inletHTTP := inlet_http.NewInletHTTP()
 
-	httpAPIComponent.RegisterHandler("callback", inletHTTP.CallBack)
-	httpAPIComponent.RegisterHandler("error", inletHTTP.Error)
+	httpAPIComponent.RegisterHandler("callback", healthMonitor.Callback(inletHTTP.CallBack))
+	httpAPIComponent.RegisterHandler("error", healthMonitor.Callback(inletHTTP.Error))
 	httpAPIComponent.RegisterHandler("nothing", inletHTTP.Nothing)
+	httpAPIComponent.RegisterHandler("progress", streamProgressHandler)
 
 	funcStartInletHTTP := func() error {
 		conf = LoadConfig("conf/inlet_http_api.conf")
+		healthMonitor.ConfigLoaded()
+
+		apiGraphProvider = NewAPIGraphProvider(API_HEADER, conf.HTTP.PATH, conf.Address, conf.Graphs, conf.GraphHooks, conf.HTTP.IPFilter)
+
+		if conf.HTTP.CircuitBreaker.Enabled {
+			circuitBreakers = NewCircuitBreakers(conf.HTTP.CircuitBreaker, conf.Address, conf.Graphs, conf.GraphHooks)
+		}
 
-		graphProvider := NewAPIGraphProvider(API_HEADER, conf.HTTP.PATH, conf.Address, conf.Graphs, conf.GraphHooks)
+		if conf.HTTP.LoadShedding.Enabled {
+			loadShedder = NewLoadShedder(conf.HTTP.LoadShedding, conf.Graphs)
+		}
 
 		httpConf := inlet_http.Config{
 			Address:    conf.HTTP.Address,
@@ -71,7 +108,7 @@ func main() {
 		emptyLogger := log.New(new(EmptyWriter), "", 0)
 
 		inletHTTP.Option(inlet_http.SetHTTPConfig(httpConf),
-			inlet_http.SetGraphProvider(graphProvider),
+			inlet_http.SetGraphProvider(apiGraphProvider),
 			inlet_http.SetResponseHandler(responseHandle),
 			inlet_http.SetErrorResponseHandler(errorResponseHandler),
 			inlet_http.SetRequestDecoder(requestDecoder),
@@ -103,47 +140,157 @@ func main() {
 			}
 		}
 
-		if httpConf.EnableStat {
-			inletHTTP.Group(conf.HTTP.PATH, func(r martini.Router) {
-				r.Post("", inletHTTP.Handler)
-				r.Post("/:apiName", inletHTTP.Handler)
-				r.Options("", optionHandle)
-				r.Options("/:apiName", optionHandle)
-			}, martini.Static("stat"))
+		healthMonitor.RendererReady()
+
+		if conf.HTTP.Stats.Enabled {
+			if collector, e := NewAPIStatsCollector(conf.HTTP.Stats.Windows, conf.HTTP.Stats.MaxSamples); e != nil {
+				panic(e)
+			} else {
+				statsCollector = collector
+			}
+		}
 
+		if policies, e := newRetryPolicies(conf.Graphs); e != nil {
+			panic(e)
 		} else {
-			inletHTTP.Group(conf.HTTP.PATH, func(r martini.Router) {
-				r.Post("", inletHTTP.Handler)
-				r.Post("/:apiName", inletHTTP.Handler)
-				r.Options("", optionHandle)
-				r.Options("/:apiName", optionHandle)
-			})
+			retryPolicies = policies
 		}
 
-		inletHTTP.Group("/", func(r martini.Router) {
-			r.Get("xdomain/proxy.html", func() string {
-				return renderedXDomainProxy
-			})
+		if policies, e := NewTimeoutPolicies(conf.HTTP.Timeout, conf.HTTP.Async.Timeout, conf.Graphs); e != nil {
+			panic(e)
+		} else {
+			timeoutPolicies = policies
+		}
 
-			r.Get("xdomain/lib/xdomain.min.js", func() string {
-				return xdomainProxyJS()
-			})
+		if apiRedactor, e := NewRedactor(conf.Redact, conf.Graphs); e != nil {
+			panic(e)
+		} else {
+			redactor = apiRedactor
+		}
+
+		if policies, e := NewCORSPolicies(conf.HTTP, conf.Graphs); e != nil {
+			panic(e)
+		} else {
+			corsPolicies = policies
+		}
+
+		if conf.HTTP.AccessLog.Enabled {
+			if logger, e := NewAccessLogger(conf.HTTP.AccessLog); e != nil {
+				panic(e)
+			} else {
+				accessLogger = logger
+			}
+		}
+
+		apiDispatcher = NewAPIDispatcher(inletHTTP)
+
+		if len(idempotentAPI) > 0 {
+			idempotencyStore = NewIdempotencyStore(conf.HTTP.Idempotency)
+			go expireIdempotencyKeys()
+		}
+
+		if conf.HTTP.Stream.Enabled {
+			streamHub = NewStreamHub(conf.HTTP.Stream)
+			go expireStreams()
+		}
+
+		if len(asyncAPI) > 0 {
+			if store, e := NewJobStore(conf.HTTP.Async); e != nil {
+				panic(e)
+			} else {
+				jobStore = store
+			}
+			go expireAsyncJobs()
 
-			r.Get("ping", func() string {
-				return "pong"
+			if allowlist, e := NewWebhookAllowlist(conf.HTTP, conf.Graphs); e != nil {
+				panic(e)
+			} else {
+				webhookAllowlist = allowlist
+			}
+
+			if deliverer, e := NewWebhookDeliverer(conf.HTTP.Webhook); e != nil {
+				panic(e)
+			} else {
+				webhookDeliverer = deliverer
+			}
+		}
+
+		apiHandle := accessLogHandle(drainHandle(loadSheddingHandle(newAPIHandler(inletHTTP))))
+
+		inletHTTP.Group(conf.HTTP.PATH, func(r martini.Router) {
+			if conf.HTTP.JSONRPC.Enabled {
+				r.Post(conf.HTTP.JSONRPC.Path, accessLogHandle(drainHandle(loadSheddingHandle(jsonRPCHandle))))
+			}
+
+			if conf.HTTP.WebSocket.Enabled {
+				drainTracker.OnDrain(closeWebSocketSessions)
+				r.Get(conf.HTTP.WebSocket.Path, webSocketHandle)
+			}
+
+			if streamHub != nil {
+				r.Post(conf.HTTP.Stream.Path, accessLogHandle(drainHandle(loadSheddingHandle(streamCallHandle))))
+				r.Get(conf.HTTP.Stream.Path+"/:streamId", accessLogHandle(streamResumeHandle))
+			}
+
+			if jobStore != nil {
+				r.Get(conf.HTTP.Async.Path+"/:jobId", accessLogHandle(asyncJobHandle))
+			}
+
+			r.Post("", apiHandle)
+			r.Post("/:apiName", apiHandle)
+			r.Options("", optionHandle)
+			r.Options("/:apiName", optionHandle)
+
+			if conf.HTTP.Stats.Enabled {
+				r.Get(conf.HTTP.Stats.Path, statsHandle)
+				r.Get(conf.HTTP.Stats.Path+"/dashboard", statsDashboardHandle)
+				r.Get(conf.HTTP.Stats.Path+"/dashboard/**", statsDashboardHandle)
+			}
+		})
+
+		if !conf.HTTP.XDomain.Disabled {
+			assets, e := NewXDomainAssets(conf.HTTP)
+			if e != nil {
+				panic(e)
+			}
+
+			inletHTTP.Group(conf.HTTP.XDomain.Prefix, func(r martini.Router) {
+				r.Get("/proxy.html", assets.Proxy.ServeHTTP)
+				r.Get("/lib/xdomain.min.js", assets.Script.ServeHTTP)
+
+				if assets.Bridge != nil {
+					r.Get("/bridge.html", assets.Bridge.ServeHTTP)
+				}
 			})
+		}
+
+		inletHTTP.Group("/", func(r martini.Router) {
+			r.Get("ping", pingHandle)
+			r.Get("healthz", healthzHandle)
+			r.Get("readyz", readyzHandle)
 		})
 
-		renderedXDomainProxy = renderXDomainProxy(conf.HTTP.AllowOrigins, conf.HTTP.PATH)
+		// the terminator and its secret are set up before the inlet serves any request
+		if conf.HTTP.TLS.Enabled {
+			if terminator, e := NewTLSTerminator(conf.HTTP.TLS, conf.HTTP.Address); e != nil {
+				panic(e)
+			} else {
+				tlsTerminator = terminator
+			}
+		}
 
 		go inletHTTP.Run()
 
+		if tlsTerminator != nil {
+			go tlsTerminator.Run()
+		}
+
 		return nil
 	}
 
 	responseRenderer = NewAPIResponseRenderer()
 
-	httpAPISpirit.Hosting(httpAPIComponent, funcStartInletHTTP).Build().Run()
+	runUntilShutdown(httpAPISpirit.Hosting(httpAPIComponent, funcStartInletHTTP).Build().Run)
 }
 
 type APIResponse struct {
@@ -154,6 +301,55 @@ type APIResponse struct {
 	Result         interface{} `json:"result"`
 }
 
+func newAPIHandler(inletHTTP *inlet_http.InletHTTP) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, _ = withAPIRequestContext(r)
+
+		if isMultiCall(r) {
+			multiCallHandle(w, r)
+			return
+		}
+
+		handler := apiSheddingHandle(healthMonitor.Track(inletHTTP.Handler))
+		if isRetryCall(r) {
+			handler = retryCallHandle
+		}
+
+		if isAsyncCall(r) {
+			handler = asyncCallHandle
+		} else {
+			applyTimeoutPolicy(w, r)
+		}
+
+		if isIdempotentCall(r) {
+			idempotentCallHandle(w, r, handler)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func newErrorAPIResponse(err error) APIResponse {
+	if errCode, ok := err.(errors.ErrCode); ok {
+		return APIResponse{
+			Code:           errCode.Code(),
+			ErrorId:        errCode.Id(),
+			ErrorNamespace: errCode.Namespace(),
+			Message:        errCode.Error(),
+			Result:         nil,
+		}
+	}
+
+	return APIResponse{
+		Code:           500,
+		ErrorId:        "",
+		ErrorNamespace: INLET_HTTP_API_ERR_NS,
+		Message:        err.Error(),
+		Result:         nil,
+	}
+}
+
 func requestDecoder(data []byte) (ret map[string]interface{}, err error) {
 	str := strings.TrimSpace(string(data))
 	if str != "" {
@@ -169,17 +365,8 @@ func requestDecoder(data []byte) (ret map[string]interface{}, err error) {
 }
 
 func requestPayloadHook(r *http.Request, apiName string, body []byte, payload *spirit.Payload) (err error) {
-	if r.Header.Get(MULTI_CALL) == "1" {
-		multiAPIReq := map[string]interface{}{}
-		if e := json.Unmarshal(body, &multiAPIReq); e != nil {
-			err = ERR_UNMARSHAL_MULTI_REQUEST_FAILED.New(errors.Params{"err": e, "api": apiName})
-			return
-		} else if reqContent, exist := multiAPIReq[apiName]; exist {
-			payload.SetContent(reqContent)
-		} else {
-			err = ERR_MULTI_API_REQUEST_NOT_EXIST.New(errors.Params{"api": apiName})
-			return
-		}
+	if ctx := apiRequestContext(r); ctx != nil && ctx.HasContent {
+		payload.SetContent(ctx.Content)
 	}
 
 	if apiName == "" {
@@ -192,7 +379,7 @@ func requestPayloadHook(r *http.Request, apiName string, body []byte, payload *s
 			newPayload := spirit.Payload{}
 
 			if e := newPayload.UnSerialize(body); e != nil {
-				err = ERR_PARSE_PROXY_PAYLOAD_FIALED.New(errors.Params{"api": apiName, "err": e})
+				err = ERR_PARSE_PROXY_PAYLOAD_FIALED.New(errors.Params{"api": apiName, "err": redactor.RedactError(apiName, e)})
 				logs.Error(err)
 				return
 			} else {
@@ -203,78 +390,70 @@ func requestPayloadHook(r *http.Request, apiName string, body []byte, payload *s
 
 	payload.SetContext(conf.HTTP.APIHeader, apiName)
 
+	clientInfo := conf.HTTP.ClientInfo(r)
+	if clientInfo.IP != nil {
+		payload.SetContext(CTX_CLIENT_IP, clientInfo.IP.String())
+	}
+	payload.SetContext(CTX_CLIENT_SCHEME, clientInfo.Scheme)
+	payload.SetContext(CTX_CLIENT_HOST, clientInfo.Host)
+
+	if subject := clientCertSubject(r); subject != "" {
+		payload.SetContext(CTX_CLIENT_CERT_SUBJECT, subject)
+	}
+
+	if ctx := apiRequestContext(r); ctx != nil {
+		payload.SetContext(CTX_REQUEST_ID, ctx.RequestId)
+		if ctx.StreamId != "" {
+			payload.SetContext(CTX_STREAM_ID, ctx.StreamId)
+		}
+		if ctx.Attempt > 0 {
+			payload.SetContext(CTX_ATTEMPT, ctx.Attempt)
+		}
+		ctx.PayloadSent = true
+		if ctx.CallbackWait == 0 {
+			ctx.CallbackWait = healthMonitor.Sent()
+		}
+	}
+
 	return
 }
 
 func optionHandle(w http.ResponseWriter, r *http.Request) {
 	if r.Method == METHOD_OPTIONS {
-		writeAccessHeaders(w, r)
 		writeBasicHeaders(w, r)
-		w.Write([]byte(""))
+		if corsPolicies.Preflight(w, r) {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+		}
 	}
 }
 
 func errorResponseHandler(err error, w http.ResponseWriter, r *http.Request) {
-	var resp APIResponse
-	if errCode, ok := err.(errors.ErrCode); ok {
-		resp = APIResponse{
-			Code:           errCode.Code(),
-			ErrorId:        errCode.Id(),
-			ErrorNamespace: errCode.Namespace(),
-			Message:        errCode.Error(),
-			Result:         nil,
-		}
-	} else {
-		resp = APIResponse{
-			Code:           500,
-			ErrorId:        "",
-			ErrorNamespace: INLET_HTTP_API_ERR_NS,
-			Message:        err.Error(),
-			Result:         nil,
-		}
+	resp := newErrorAPIResponse(err)
+
+	// only errors after the payload was sent, as graph timeouts, are failures of the downstream
+	apiName := requestAPIName(r)
+	if ctx := apiRequestContext(r); ctx != nil && ctx.PayloadSent {
+		circuitBreakers.Record(apiName, true)
 	}
 
-	apiName := r.Header.Get(conf.HTTP.APIHeader)
-	if text, e := responseRenderer.Render(false, map[string]APIResponse{apiName: resp}); e != nil {
-		err := ERR_API_RESPONSE_REDNER_FAILED.New(errors.Params{"err": e})
-		eResp := APIResponse{
-			Code:           err.Code(),
-			ErrorId:        err.Id(),
-			ErrorNamespace: err.Namespace(),
-			Message:        err.Error(),
-			Result:         nil,
-		}
-		writeResponse(&eResp, w, r)
-		return
-	} else {
-		writeTextResponse(text, w, r)
+	finishAPIRequest(r, map[string]APIResponse{apiName: resp})
+
+	if isSubCall(r) {
 		return
 	}
+
+	writeAPIResponses(false, map[string]APIResponse{apiName: resp}, w, r)
 }
 
 func responseHandle(graphsResponse map[string]inlet_http.GraphResponse, w http.ResponseWriter, r *http.Request) {
-	isMultiCall := r.Header.Get(MULTI_CALL) == "1"
-
 	multiResp := map[string]APIResponse{}
 	for apiName, graphResponse := range graphsResponse {
+		circuitBreakers.Record(apiName, graphResponse.Error != nil)
+
 		if graphResponse.Error != nil {
-			if errCode, ok := graphResponse.Error.(errors.ErrCode); ok {
-				multiResp[apiName] = APIResponse{
-					Code:           errCode.Code(),
-					ErrorId:        errCode.Id(),
-					ErrorNamespace: errCode.Namespace(),
-					Message:        errCode.Error(),
-					Result:         nil,
-				}
-			} else {
-				multiResp[apiName] = APIResponse{
-					Code:           500,
-					ErrorId:        "",
-					ErrorNamespace: INLET_HTTP_API_ERR_NS,
-					Message:        graphResponse.Error.Error(),
-					Result:         nil,
-				}
-			}
+			multiResp[apiName] = newErrorAPIResponse(graphResponse.Error)
 		} else if graphResponse.RespPayload.IsCorrect() {
 			multiResp[apiName] = APIResponse{
 				Code:   graphResponse.RespPayload.Error().Code,
@@ -291,7 +470,22 @@ func responseHandle(graphsResponse map[string]inlet_http.GraphResponse, w http.R
 		}
 	}
 
-	if text, e := responseRenderer.Render(isMultiCall, multiResp); e != nil {
+	finishAPIRequest(r, multiResp)
+
+	if isSubCall(r) {
+		return
+	}
+
+	writeAPIResponses(isMultiCall(r), multiResp, w, r)
+}
+
+func writeAPIResponses(isMulti bool, responses map[string]APIResponse, w http.ResponseWriter, r *http.Request) {
+	opts := RenderOptions{Fields: requestFieldSelection(r)}
+	if ctx := apiRequestContext(r); ctx != nil {
+		opts.Aliases = ctx.Aliases
+	}
+
+	if text, e := responseRenderer.RenderWithOptions(isMulti, responses, opts); e != nil {
 		err := ERR_API_RESPONSE_REDNER_FAILED.New(errors.Params{"err": e})
 		resp := APIResponse{
 			Code:           err.Code(),
@@ -315,6 +509,12 @@ func sha1hash(hash []byte) []byte {
 }
 
 func signatureResponse(data []byte, w http.ResponseWriter) {
+	if signature, ok := signData(data); ok {
+		w.Header().Set(conf.HTTP.Signature.Header, signature)
+	}
+}
+
+func signData(data []byte) (signature string, ok bool) {
 	if !conf.HTTP.Signature.Enabled {
 		return
 	}
@@ -322,17 +522,27 @@ func signatureResponse(data []byte, w http.ResponseWriter) {
 	if bSignature, err := rsa.SignPKCS1v15(rand.Reader, conf.HTTP.Signature._PrivateKey, crypto.SHA1, sha1hash(data)); err != nil {
 		logs.Error(err)
 	} else {
-		signature := base64.StdEncoding.EncodeToString(bSignature)
-		w.Header().Set(conf.HTTP.Signature.Header, signature)
+		signature = base64.StdEncoding.EncodeToString(bSignature)
+		ok = true
 	}
+	return
 }
 
 func writeTextResponse(text string, w http.ResponseWriter, r *http.Request) {
+	writeTextResponseWithStatusCode(text, w, r, http.StatusOK)
+}
+
+func writeTextResponseWithStatusCode(text string, w http.ResponseWriter, r *http.Request, code int) {
+	if ctx := apiRequestContext(r); ctx != nil {
+		ctx.Rendered = text
+		ctx.RenderedStatus = code
+	}
+
 	writeAccessHeaders(w, r)
 	writeBasicHeaders(w, r)
 	signatureResponse([]byte(text), w)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(code)
 	w.Write([]byte(text))
 }
 
@@ -348,33 +558,12 @@ func writeResponseWithStatusCode(v interface{}, w http.ResponseWriter, r *http.R
 			writeResponseWithStatusCode(&err, w, r, code)
 		}
 	} else {
-		writeAccessHeaders(w, r)
-		writeBasicHeaders(w, r)
-		signatureResponse(data, w)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(code)
-		w.Write(data)
+		writeTextResponseWithStatusCode(string(data), w, r, code)
 	}
 }
 
 func writeAccessHeaders(w http.ResponseWriter, r *http.Request) {
-	refer := r.Referer()
-	if refer == "" {
-		refer = r.Header.Get("Origin")
-	}
-
-	if refProtocol, refDomain, isAllowd := conf.HTTP.ParseOrigin(refer); isAllowd {
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		origin := refProtocol + "://" + refDomain
-		if origin == "://" ||
-			refProtocol == "chrome-extension" { //issue of post man, chrome limit.
-			origin = "*"
-		}
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-	}
-
-	w.Header().Set("Access-Control-Allow-Methods", "POST")
-	w.Header().Set("Access-Control-Allow-Headers", conf.HTTP.allowHeaders())
+	corsPolicies.WriteHeaders(w, r)
 }
 
 func writeBasicHeaders(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gogap/errors"
+)
+
+const (
+	DEFAULT_MULTI_CALL_MAX_BATCH_SIZE = 20
+)
+
+func isMultiCall(r *http.Request) bool {
+	return r.Header.Get(MULTI_CALL) == "1"
+}
+
+var (
+	multiCallAliasRegexp = regexp.MustCompile(`^[A-Za-z0-9_.:\-]+$`)
+)
+
+type multiCallItem struct {
+	Alias  string      `json:"alias"`
+	API    string      `json:"api"`
+	Params interface{} `json:"params"`
+}
+
+// parseMultiCall returns chained for the array form, the only one whose
+// params could reference the responses of other calls by $ref
+func parseMultiCall(body []byte) (calls []APICall, chained bool, err error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		calls, err = parseMultiCallItems(body)
+		return calls, true, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	apiParams := map[string]interface{}{}
+	if e := decoder.Decode(&apiParams); e != nil {
+		err = ERR_UNMARSHAL_MULTI_REQUEST_BODY_FAILED.New(errors.Params{"err": redactor.RedactError("", e)})
+		return
+	}
+
+	if len(apiParams) == 0 {
+		err = ERR_EMPTY_MULTI_API_REQUEST.New()
+		return
+	}
+
+	apiNames := []string{}
+	for apiName := range apiParams {
+		apiNames = append(apiNames, apiName)
+	}
+	sort.Strings(apiNames)
+
+	for _, apiName := range apiNames {
+		calls = append(calls, APICall{
+			Name:    apiName,
+			API:     strings.TrimSpace(apiName),
+			Content: apiParams[apiName],
+		})
+	}
+
+	return
+}
+
+func parseMultiCallItems(body []byte) (calls []APICall, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	items := []multiCallItem{}
+	if e := decoder.Decode(&items); e != nil {
+		err = ERR_UNMARSHAL_MULTI_REQUEST_BODY_FAILED.New(errors.Params{"err": redactor.RedactError("", e)})
+		return
+	}
+
+	if len(items) == 0 {
+		err = ERR_EMPTY_MULTI_API_REQUEST.New()
+		return
+	}
+
+	aliases := map[string]bool{}
+	for _, item := range items {
+		item.API = strings.TrimSpace(item.API)
+		item.Alias = strings.TrimSpace(item.Alias)
+
+		if item.Alias == "" {
+			item.Alias = item.API
+		}
+
+		if !multiCallAliasRegexp.MatchString(item.Alias) {
+			err = ERR_MULTI_CALL_BAD_ALIAS.New(errors.Params{"alias": item.Alias})
+			return
+		}
+
+		if aliases[item.Alias] {
+			err = ERR_MULTI_CALL_DUPLICATE_ALIAS.New(errors.Params{"alias": item.Alias})
+			return
+		}
+		aliases[item.Alias] = true
+
+		calls = append(calls, APICall{
+			Name:    item.Alias,
+			API:     item.API,
+			Content: item.Params,
+		})
+	}
+
+	return
+}
+
+func multiCallAPIName(r *http.Request, name string) string {
+	if ctx := apiRequestContext(r); ctx != nil {
+		if api, exist := ctx.Aliases[name]; exist {
+			return api
+		}
+	}
+	return strings.TrimSpace(name)
+}
+
+func subCallTimeout(r *http.Request) time.Duration {
+	if timeout := parseTimeoutHeader(r, SUB_CALL_TIMEOUT); timeout > 0 {
+		return timeout
+	}
+
+	if conf.HTTP.MultiCall.SubCallTimeout > 0 {
+		return time.Duration(conf.HTTP.MultiCall.SubCallTimeout) * time.Millisecond
+	}
+
+	return parseTimeoutHeader(r, API_CALL_TIMEOUT)
+}
+
+func multiCallHandle(w http.ResponseWriter, r *http.Request) {
+	body, e := readRequestBody(r)
+	if e != nil {
+		errorResponseHandler(ERR_UNMARSHAL_MULTI_REQUEST_BODY_FAILED.New(errors.Params{"err": e}), w, r)
+		return
+	}
+
+	calls, chained, err := parseMultiCall(body)
+	if err != nil {
+		errorResponseHandler(err, w, r)
+		return
+	}
+
+	if maxSize := conf.HTTP.MultiCall.MaxBatchSize; len(calls) > maxSize {
+		errorResponseHandler(ERR_MULTI_CALL_TOO_MANY_APIS.New(errors.Params{"count": len(calls), "max": maxSize}), w, r)
+		return
+	}
+
+	timeout := subCallTimeout(r)
+
+	responses := map[string]APIResponse{}
+	pending := []APICall{}
+	aliases := map[string]string{}
+
+	for _, call := range calls {
+		aliases[call.Name] = call.API
+
+		if _, e := apiGraphProvider.Resolve(r, call.API); e != nil {
+			responses[call.Name] = newErrorAPIResponse(e)
+			continue
+		}
+
+		call.Timeout = effectiveTimeout(call.API, timeout)
+		pending = append(pending, call)
+	}
+
+	writeEffectiveTimeout(w, longestCallTimeout(pending))
+
+	if chained {
+		apiDispatcher.CallGraph(r, pending, responses)
+	} else {
+		for name, resp := range apiDispatcher.CallAll(r, pending) {
+			responses[name] = resp
+		}
+	}
+
+	if ctx := apiRequestContext(r); ctx != nil {
+		ctx.Aliases = aliases
+		ctx.Responses = responses
+	}
+
+	writeAPIResponses(true, responses, w, r)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gogap/errors"
+)
+
+const (
+	MULTI_CALL_REF_PREFIX = "$ref:"
+)
+
+type multiCallRef struct {
+	Name    string
+	Pointer string
+}
+
+func parseMultiCallRef(value string) (ref multiCallRef, ok bool) {
+	if !strings.HasPrefix(value, MULTI_CALL_REF_PREFIX) {
+		return
+	}
+
+	value = strings.TrimPrefix(value, MULTI_CALL_REF_PREFIX)
+	if i := strings.Index(value, "/"); i >= 0 {
+		ref.Name = value[:i]
+		ref.Pointer = value[i:]
+	} else {
+		ref.Name = value
+	}
+
+	ref.Name = strings.TrimSpace(ref.Name)
+	ok = ref.Name != ""
+
+	return
+}
+
+func collectMultiCallRefs(v interface{}, refs map[string]bool) {
+	switch value := v.(type) {
+	case string:
+		if ref, ok := parseMultiCallRef(value); ok {
+			refs[ref.Name] = true
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			collectMultiCallRefs(item, refs)
+		}
+	case []interface{}:
+		for _, item := range value {
+			collectMultiCallRefs(item, refs)
+		}
+	}
+}
+
+func resolveMultiCallRefs(v interface{}, responses map[string]APIResponse) (ret interface{}, failedRef string, err error) {
+	switch value := v.(type) {
+	case string:
+		ref, ok := parseMultiCallRef(value)
+		if !ok {
+			return value, "", nil
+		}
+
+		resp, exist := responses[ref.Name]
+		if !exist {
+			return nil, ref.Name, fmt.Errorf("api %s has no response", ref.Name)
+		}
+
+		var doc interface{}
+		if doc, err = responseDocument(resp); err == nil {
+			ret, err = jsonPointer(doc, ref.Pointer)
+		}
+
+		if err != nil {
+			failedRef = ref.Name
+		}
+		return
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			if resolved[key], failedRef, err = resolveMultiCallRefs(item, responses); err != nil {
+				return
+			}
+		}
+		return resolved, "", nil
+	case []interface{}:
+		resolved := make([]interface{}, len(value))
+		for i, item := range value {
+			if resolved[i], failedRef, err = resolveMultiCallRefs(item, responses); err != nil {
+				return
+			}
+		}
+		return resolved, "", nil
+	}
+
+	return v, "", nil
+}
+
+func responseDocument(resp APIResponse) (doc interface{}, err error) {
+	var data []byte
+	if data, err = json.Marshal(resp); err != nil {
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&doc)
+
+	return
+}
+
+func jsonPointer(doc interface{}, pointer string) (v interface{}, err error) {
+	if pointer == "" {
+		return doc, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		err = fmt.Errorf("bad json pointer %q", pointer)
+		return
+	}
+
+	v = doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+
+		switch value := v.(type) {
+		case map[string]interface{}:
+			item, exist := value[token]
+			if !exist {
+				err = fmt.Errorf("json pointer %q not found", pointer)
+				return
+			}
+			v = item
+		case []interface{}:
+			index, e := strconv.Atoi(token)
+			if e != nil || index < 0 || index >= len(value) {
+				err = fmt.Errorf("json pointer %q index out of range", pointer)
+				return
+			}
+			v = value[index]
+		default:
+			err = fmt.Errorf("json pointer %q not found", pointer)
+			return
+		}
+	}
+
+	return
+}
+
+func (p *APIDispatcher) CallGraph(parent *http.Request, calls []APICall, responses map[string]APIResponse) {
+	deps := map[string][]string{}
+	pending := map[string]APICall{}
+
+	for _, call := range calls {
+		refs := map[string]bool{}
+		collectMultiCallRefs(call.Content, refs)
+
+		for name := range refs {
+			deps[call.Name] = append(deps[call.Name], name)
+		}
+		pending[call.Name] = call
+	}
+
+	for name := range pending {
+		for _, dep := range deps[name] {
+			if _, exist := pending[dep]; exist {
+				continue
+			}
+			if _, exist := responses[dep]; exist {
+				continue
+			}
+
+			responses[name] = newErrorAPIResponse(ERR_MULTI_CALL_UNRESOLVED_REF.New(errors.Params{"api": name, "ref": dep, "reason": "api not in multi call"}))
+			delete(pending, name)
+			break
+		}
+	}
+
+	for len(pending) > 0 {
+		names := []string{}
+
+	nextPending:
+		for name := range pending {
+			for _, dep := range deps[name] {
+				if _, waiting := pending[dep]; waiting {
+					continue nextPending
+				}
+			}
+			names = append(names, name)
+		}
+
+		if len(names) == 0 {
+			p.failCycles(pending, deps, responses)
+			return
+		}
+
+		ready := []APICall{}
+
+	nextCall:
+		for _, name := range names {
+			call := pending[name]
+			delete(pending, name)
+
+			for _, dep := range deps[name] {
+				if responses[dep].Code != 0 {
+					responses[name] = newErrorAPIResponse(ERR_MULTI_CALL_UNRESOLVED_REF.New(errors.Params{"api": name, "ref": dep, "reason": "dependency failed"}))
+					continue nextCall
+				}
+			}
+
+			content, ref, e := resolveMultiCallRefs(call.Content, responses)
+			if e != nil {
+				responses[name] = newErrorAPIResponse(ERR_MULTI_CALL_UNRESOLVED_REF.New(errors.Params{"api": name, "ref": ref, "reason": e}))
+				continue
+			}
+
+			call.Content = content
+			ready = append(ready, call)
+		}
+
+		for name, resp := range p.CallAll(parent, ready) {
+			responses[name] = resp
+		}
+	}
+}
+
+func (p *APIDispatcher) failCycles(pending map[string]APICall, deps map[string][]string, responses map[string]APIResponse) {
+	cyclic := map[string]bool{}
+	for name := range pending {
+		cyclic[name] = true
+	}
+
+	for removed := true; removed; {
+		removed = false
+
+		dependents := map[string]bool{}
+		for name := range cyclic {
+			for _, dep := range deps[name] {
+				dependents[dep] = true
+			}
+		}
+
+		for name := range cyclic {
+			if !dependents[name] {
+				delete(cyclic, name)
+				removed = true
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range cyclic {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for name := range pending {
+		if cyclic[name] {
+			responses[name] = newErrorAPIResponse(ERR_MULTI_CALL_CYCLIC_REF.New(errors.Params{"apis": strings.Join(names, ",")}))
+		} else {
+			responses[name] = newErrorAPIResponse(ERR_MULTI_CALL_UNRESOLVED_REF.New(errors.Params{"api": name, "ref": strings.Join(names, ","), "reason": "depends on cyclic reference"}))
+		}
+	}
+}
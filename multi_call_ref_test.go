@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseMultiCallRef(t *testing.T) {
+	tests := []struct {
+		value string
+		ref   multiCallRef
+		ok    bool
+	}{
+		{value: "$ref:api.user.get", ref: multiCallRef{Name: "api.user.get"}, ok: true},
+		{value: "$ref:api.user.get/result/id", ref: multiCallRef{Name: "api.user.get", Pointer: "/result/id"}, ok: true},
+		{value: "$ref: user /result", ref: multiCallRef{Name: "user", Pointer: "/result"}, ok: true},
+		{value: "$ref:/result", ok: false},
+		{value: "$ref:", ok: false},
+		{value: "ref:api.user.get", ok: false},
+		{value: "api.user.get", ok: false},
+	}
+
+	for _, test := range tests {
+		ref, ok := parseMultiCallRef(test.value)
+		if ok != test.ok || (ok && ref != test.ref) {
+			t.Errorf("parseMultiCallRef(%q) = %+v, %v, want %+v, %v", test.value, ref, ok, test.ref, test.ok)
+		}
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	doc := map[string]interface{}{
+		"result": map[string]interface{}{
+			"id":    json.Number("7"),
+			"tags":  []interface{}{"a", "b"},
+			"a/b":   "slash",
+			"m~n":   "tilde",
+			"empty": nil,
+		},
+	}
+
+	tests := []struct {
+		pointer string
+		value   interface{}
+		bad     bool
+	}{
+		{pointer: "", value: doc},
+		{pointer: "/result/id", value: json.Number("7")},
+		{pointer: "/result/tags/1", value: "b"},
+		{pointer: "/result/a~1b", value: "slash"},
+		{pointer: "/result/m~0n", value: "tilde"},
+		{pointer: "/result/empty", value: nil},
+
+		{pointer: "result/id", bad: true},
+		{pointer: "/result/missing", bad: true},
+		{pointer: "/result/tags/2", bad: true},
+		{pointer: "/result/tags/-1", bad: true},
+		{pointer: "/result/tags/x", bad: true},
+		{pointer: "/result/id/deeper", bad: true},
+	}
+
+	for _, test := range tests {
+		value, err := jsonPointer(doc, test.pointer)
+		if test.bad {
+			if err == nil {
+				t.Errorf("jsonPointer(%q) = %v, want error", test.pointer, value)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("jsonPointer(%q) failed: %s", test.pointer, err)
+		} else if !reflect.DeepEqual(value, test.value) {
+			t.Errorf("jsonPointer(%q) = %v, want %v", test.pointer, value, test.value)
+		}
+	}
+}
+
+func TestResolveMultiCallRefs(t *testing.T) {
+	responses := map[string]APIResponse{
+		"user": {Result: map[string]interface{}{"id": 7, "name": "a"}},
+	}
+
+	tests := []struct {
+		content   string
+		resolved  string
+		failedRef string
+	}{
+		{content: `{"id":"$ref:user/result/id"}`, resolved: `{"id":7}`},
+		{content: `{"ids":["$ref:user/result/id",1],"name":"b"}`, resolved: `{"ids":[7,1],"name":"b"}`},
+		{content: `{"user":"$ref:user/result"}`, resolved: `{"user":{"id":7,"name":"a"}}`},
+		{content: `"plain"`, resolved: `"plain"`},
+		{content: `{"a":"$ref:task/result"}`, failedRef: "task"},
+		{content: `{"a":"$ref:user/result/missing"}`, failedRef: "user"},
+	}
+
+	for _, test := range tests {
+		var content interface{}
+		json.Unmarshal([]byte(test.content), &content)
+
+		resolved, failedRef, err := resolveMultiCallRefs(content, responses)
+		if test.failedRef != "" {
+			if err == nil || failedRef != test.failedRef {
+				t.Errorf("resolveMultiCallRefs(%s) failed ref %q, error %v, want %q", test.content, failedRef, err, test.failedRef)
+			}
+			continue
+		}
+
+		data, _ := json.Marshal(resolved)
+		if err != nil || string(data) != test.resolved {
+			t.Errorf("resolveMultiCallRefs(%s) = %s, %v, want %s", test.content, data, err, test.resolved)
+		}
+	}
+}
+
+func TestCallGraphUnresolved(t *testing.T) {
+	refCode := ERR_MULTI_CALL_UNRESOLVED_REF.New().Code()
+	cycleCode := ERR_MULTI_CALL_CYCLIC_REF.New().Code()
+
+	tests := []struct {
+		name  string
+		calls map[string]string
+		codes map[string]uint64
+	}{
+		{
+			name:  "missing ref",
+			calls: map[string]string{"a": `{"id":"$ref:x/result"}`},
+			codes: map[string]uint64{"a": refCode},
+		},
+		{
+			name:  "self cycle",
+			calls: map[string]string{"a": `{"id":"$ref:a/result"}`},
+			codes: map[string]uint64{"a": cycleCode},
+		},
+		{
+			name: "cycle and dependent",
+			calls: map[string]string{
+				"a": `{"id":"$ref:b/result"}`,
+				"b": `{"id":["$ref:a/result"]}`,
+				"c": `{"id":"$ref:a/result"}`,
+			},
+			codes: map[string]uint64{"a": cycleCode, "b": cycleCode, "c": refCode},
+		},
+		{
+			name: "three way cycle",
+			calls: map[string]string{
+				"a": `{"id":"$ref:b"}`,
+				"b": `{"id":"$ref:c"}`,
+				"c": `{"id":"$ref:a"}`,
+			},
+			codes: map[string]uint64{"a": cycleCode, "b": cycleCode, "c": cycleCode},
+		},
+	}
+
+	for _, test := range tests {
+		calls := []APICall{}
+		for name, content := range test.calls {
+			var v interface{}
+			json.Unmarshal([]byte(content), &v)
+			calls = append(calls, APICall{Name: name, API: name, Content: v})
+		}
+
+		responses := map[string]APIResponse{}
+		(&APIDispatcher{}).CallGraph(nil, calls, responses)
+
+		for name, code := range test.codes {
+			if responses[name].Code != code {
+				t.Errorf("%s: response code of %s = %d, want %d", test.name, name, responses[name].Code, code)
+			}
+		}
+	}
+}
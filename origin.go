@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	MAX_ORIGIN_LENGTH = 2048
+)
+
+type Origin struct {
+	Scheme string
+	Host   string
+	Port   string
+}
+
+func ParseOrigin(value string) (origin Origin, err error) {
+	value = strings.TrimSpace(value)
+
+	if value == "" || value == "null" {
+		err = fmt.Errorf("empty or opaque origin")
+		return
+	}
+
+	if len(value) > MAX_ORIGIN_LENGTH {
+		err = fmt.Errorf("origin too long")
+		return
+	}
+
+	if !utf8.ValidString(value) || strings.IndexFunc(value, isControlRune) >= 0 {
+		err = fmt.Errorf("origin contains invalid characters")
+		return
+	}
+
+	u, e := url.Parse(value)
+	if e != nil {
+		err = e
+		return
+	}
+
+	if u.Scheme == "" || u.Opaque != "" || u.Host == "" {
+		err = fmt.Errorf("origin should be in form of scheme://host[:port], origin: %q", value)
+		return
+	}
+
+	if u.User != nil {
+		err = fmt.Errorf("origin should not contain user info")
+		return
+	}
+
+	origin.Scheme = strings.ToLower(u.Scheme)
+
+	if origin.Host, err = normalizeHost(u.Hostname()); err != nil {
+		return
+	}
+
+	if origin.Port, err = normalizePort(origin.Scheme, u.Port()); err != nil {
+		return
+	}
+
+	if strings.HasSuffix(u.Host, ":") {
+		err = fmt.Errorf("origin has empty port")
+		return
+	}
+
+	return
+}
+
+func (p Origin) String() string {
+	host := p.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	if p.Port != "" {
+		host += ":" + p.Port
+	}
+
+	return p.Scheme + "://" + host
+}
+
+func isControlRune(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
+
+func normalizePort(scheme, port string) (string, error) {
+	if port == "" {
+		return "", nil
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return "", fmt.Errorf("bad port: %q", port)
+	} else {
+		port = strconv.Itoa(n)
+	}
+
+	if port == defaultPort(scheme) {
+		return "", nil
+	}
+
+	return port, nil
+}
+
+// underscores are common in internal host names and browsers accept them
+var originIDNA = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("empty host")
+	}
+
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		ip := net.ParseIP(host[1 : len(host)-1])
+		if ip == nil || ip.To4() != nil {
+			return "", fmt.Errorf("bad ipv6 host: %q", host)
+		}
+		return ip.String(), nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := originIDNA.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", fmt.Errorf("bad host %q, error: %s", host, err)
+	}
+
+	labels := strings.Split(ascii, ".")
+	for _, label := range labels {
+		if label == "" {
+			return "", fmt.Errorf("empty label in host: %q", host)
+		}
+
+		for _, c := range []byte(label) {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return "", fmt.Errorf("bad character in host: %q", host)
+			}
+		}
+
+		if len(label) > 63 {
+			return "", fmt.Errorf("label too long in host: %q", host)
+		}
+	}
+
+	return ascii, nil
+}
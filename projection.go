@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	API_FIELDS_HEADER = "X-Api-Fields"
+	API_FIELDS_PARAM  = "fields"
+)
+
+type FieldSelection struct {
+	all      bool
+	children map[string]*FieldSelection
+}
+
+func ParseFieldSelection(value string) *FieldSelection {
+	selection := &FieldSelection{children: make(map[string]*FieldSelection)}
+
+	for _, field := range strings.Split(value, ",") {
+		keys := []string{}
+		for _, key := range strings.Split(field, ".") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+
+		if len(keys) > 0 {
+			selection.add(keys)
+		}
+	}
+
+	if len(selection.children) == 0 {
+		return nil
+	}
+
+	return selection
+}
+
+func requestFieldSelection(r *http.Request) *FieldSelection {
+	value := r.Header.Get(API_FIELDS_HEADER)
+	if value == "" {
+		value = r.URL.Query().Get(API_FIELDS_PARAM)
+	}
+
+	return ParseFieldSelection(value)
+}
+
+func (p *FieldSelection) add(keys []string) {
+	node := p
+	for _, key := range keys {
+		if node.all {
+			return
+		}
+
+		child, exist := node.children[key]
+		if !exist {
+			child = &FieldSelection{children: make(map[string]*FieldSelection)}
+			node.children[key] = child
+		}
+		node = child
+	}
+
+	node.all = true
+	node.children = nil
+}
+
+func (p *FieldSelection) Project(v interface{}) interface{} {
+	if p == nil || p.all {
+		return v
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		projected := make(map[string]interface{})
+		for key, child := range p.children {
+			if item, exist := value[key]; exist {
+				projected[key] = child.Project(item)
+			}
+		}
+		return projected
+	case []interface{}:
+		projected := make([]interface{}, len(value))
+		for i, item := range value {
+			projected[i] = p.Project(item)
+		}
+		return projected
+	}
+
+	return v
+}
+
+func (p *FieldSelection) ProjectResponse(resp APIResponse) APIResponse {
+	if p == nil || resp.Code != 0 || resp.Result == nil {
+		return resp
+	}
+
+	data, err := json.Marshal(resp.Result)
+	if err != nil {
+		return resp
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var result interface{}
+	if err = decoder.Decode(&result); err != nil {
+		return resp
+	}
+
+	resp.Result = p.Project(result)
+
+	return resp
+}
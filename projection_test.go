@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFieldSelectionProject(t *testing.T) {
+	doc := `{"id":7,"name":"a","profile":{"age":3,"email":"e","tags":["x"]},"tasks":[{"id":1,"title":"t1","done":true},{"id":2,"title":"t2"}],"count":2}`
+
+	tests := []struct {
+		fields    string
+		projected string
+	}{
+		{fields: "id", projected: `{"id":7}`},
+		{fields: "id,name", projected: `{"id":7,"name":"a"}`},
+		{fields: " id , name ", projected: `{"id":7,"name":"a"}`},
+		{fields: "profile.age", projected: `{"profile":{"age":3}}`},
+		{fields: "profile.age,profile", projected: `{"profile":{"age":3,"email":"e","tags":["x"]}}`},
+		{fields: "profile,profile.age", projected: `{"profile":{"age":3,"email":"e","tags":["x"]}}`},
+		{fields: "tasks.id", projected: `{"tasks":[{"id":1},{"id":2}]}`},
+		{fields: "tasks.done", projected: `{"tasks":[{"done":true},{}]}`},
+		{fields: "missing", projected: `{}`},
+		{fields: "id.deeper", projected: `{"id":7}`},
+		{fields: "profile..age", projected: `{"profile":{"age":3}}`},
+		{fields: "", projected: doc},
+		{fields: ", ,", projected: doc},
+	}
+
+	for _, test := range tests {
+		var v interface{}
+		json.Unmarshal([]byte(doc), &v)
+
+		data, _ := json.Marshal(ParseFieldSelection(test.fields).Project(v))
+
+		var expected interface{}
+		json.Unmarshal([]byte(test.projected), &expected)
+		expectedData, _ := json.Marshal(expected)
+
+		if string(data) != string(expectedData) {
+			t.Errorf("Project(%q) = %s, want %s", test.fields, data, expectedData)
+		}
+	}
+}
+
+func TestFieldSelectionProjectResponse(t *testing.T) {
+	selection := ParseFieldSelection("id")
+
+	tests := []struct {
+		resp   APIResponse
+		result string
+	}{
+		{resp: APIResponse{Result: map[string]interface{}{"id": 7, "name": "a"}}, result: `{"id":7}`},
+		{resp: APIResponse{Result: []interface{}{map[string]interface{}{"id": 1, "name": "a"}}}, result: `[{"id":1}]`},
+		{resp: APIResponse{Result: "text"}, result: `"text"`},
+		{resp: APIResponse{Result: nil}, result: `null`},
+		{resp: APIResponse{Code: 500, Result: map[string]interface{}{"id": 7, "name": "a"}}, result: `{"id":7,"name":"a"}`},
+	}
+
+	for _, test := range tests {
+		data, _ := json.Marshal(selection.ProjectResponse(test.resp).Result)
+		if string(data) != test.result {
+			t.Errorf("ProjectResponse(%+v) = %s, want %s", test.resp, data, test.result)
+		}
+	}
+}
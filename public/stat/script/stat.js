@@ -1,122 +1,160 @@
-/**
- * Created by oboo_chin on 15/3/16.
- */
-
-var model = {
-    dataOutput: {},
-    formHeader: {},
-    classNames: {}
-};
-
-
 var Statistics = {
+    url: "",
+    token: "",
+    header: "X-Stats-Token",
+    timer: null,
+    data: null,
+
     /**
-     * 初始化操作
-     * @param {json} data 传入的原始数据
+     * @param {string} url stats json endpoint
+     * @param {string} search location search, token could be passed by ?token=
+     * @param {string} header configured stats token header
      * */
-    init:function (data) {
+    init: function (url, search, header) {
+        Statistics.url = url;
+        if (header) {
+            Statistics.header = header;
+        }
 
-        for (var itemName in data) {
+        var match = /[?&]token=([^&]*)/.exec(search || "");
+        if (match) {
+            Statistics.token = decodeURIComponent(match[1]);
+        }
 
-            var itemData = {};
-            itemData[itemName] = data[itemName];
-            var itemClass = itemName.split(".")[1];
+        document.getElementById("window").onchange = Statistics.render;
+        document.getElementById("filter").oninput = Statistics.render;
+        document.getElementById("interval").onchange = Statistics.schedule;
 
-            if (Statistics.classCheck(itemClass)) {
+        Statistics.load();
+        Statistics.schedule();
+    },
 
-                //添加新类并投入新类
-                Statistics.addClass(itemClass);
-                Statistics.dataDrop(itemClass, itemData);
+    schedule: function () {
+        if (Statistics.timer) {
+            clearInterval(Statistics.timer);
+            Statistics.timer = null;
+        }
 
-            } else {
-                //投入类
-                Statistics.dataDrop(itemClass, itemData);
-            }
+        var seconds = parseInt(document.getElementById("interval").value, 10);
+        if (seconds > 0) {
+            Statistics.timer = setInterval(Statistics.load, seconds * 1000);
         }
+    },
 
-        Statistics.outputData(model.classNames);
+    load: function () {
+        var xhr = new XMLHttpRequest();
+        xhr.open("GET", Statistics.url, true);
+        if (Statistics.token) {
+            xhr.setRequestHeader(Statistics.header, Statistics.token);
+        }
+        xhr.onreadystatechange = function () {
+            if (xhr.readyState !== 4) {
+                return;
+            }
 
-    },
+            if (xhr.status !== 200) {
+                Statistics.status("load failed: " + xhr.status);
+                return;
+            }
 
-    /**
-     * 输出数据
-     * @param {json} classNames 传入重构json
-     * */
-    outputData: function (classNames) {
+            try {
+                Statistics.data = JSON.parse(xhr.responseText);
+            } catch (e) {
+                Statistics.status("bad response: " + e);
+                return;
+            }
 
-        var data = {dataList: classNames};
+            Statistics.status("updated " + new Date().toLocaleTimeString());
+            Statistics.windows(Statistics.data.windows || []);
+            Statistics.render();
+        };
+        xhr.send();
+    },
 
-        var html = template('line', data);
-        document.getElementById('content').innerHTML = html;
+    status: function (text) {
+        document.getElementById("status").textContent = text;
     },
-    /**
-     * 向数据栈加入数据
-     * @param {json} className 传入数据栈json
-     * @param {json} data 传入的数据栈
-     * */
-    dataDrop: function (className, data) {
-        for (var name in data) {
-            model.classNames[className][name] = data[name]
+
+    windows: function (names) {
+        var select = document.getElementById("window");
+        if (select.options.length === names.length) {
+            return;
         }
-    },
-    /**
-     * 检测class存在性
-     * @param {json} className 类名
-     * */
-    classCheck: function (className) {
 
-        if (model.classNames[className]) {
-            return false;
-        } else {
-            return true;
+        select.innerHTML = "";
+        for (var i = 0; i < names.length; i++) {
+            var option = document.createElement("option");
+            option.value = names[i];
+            option.textContent = names[i];
+            select.appendChild(option);
         }
     },
 
-    /**
-     * 加入类名
-     * @param {json} className 类名
-     * */
-    addClass: function (className) {
-        model.classNames[className] = {};
-    },
-    tmp: {
-        /**
-         * 创建table
-         * @param {string} name table名
-         * @param {json} data 数据
-         * */
-        consTable: function (name, data) {
-            var table = $("<table/>").attr("id", name);
-            table.appendTo($("body"));
-            table.id = name;
-            for (var tr in data) {
-                Statistics.tmp.consTr(tr, data[tr], table);
-            }
-        },
-        /**
-         * 创建table
-         * @param {string} name tr名
-         * @param {json} data 数据
-         * @param {object} table table节点
-         * */
-        consTr: function (name, data, table) {
-            var tr = $("<tr/>");
-            $("<td/>").text(name).appendTo(tr);
-            for (var item in data) {
-                Statistics.tmp.formHeader[item] = 1;
-                $("<td/>").text(data[item]).appendTo(tr);
+    render: function () {
+        var data = Statistics.data;
+        if (!data) {
+            return;
+        }
+
+        document.getElementById("uptime").textContent = "uptime " + data.uptime;
+
+        var window = document.getElementById("window").value;
+        var filter = document.getElementById("filter").value;
+        var names = [];
+        for (var name in data.apis) {
+            if (!filter || name.indexOf(filter) >= 0) {
+                names.push(name);
             }
-            tr.appendTo(table);
-        },
-        /**
-         * 创建操作条
-         * */
-        consBar: function () {
-            $("<div/>").attr("id", "jump").appendTo($("body"));
-            for (var item in  model.classNames) {
-                $("<a/>").text(item).attr("src", "#" + item).appendTo($("#jump"));
+        }
+        names.sort();
+
+        var tbody = document.querySelector("#content tbody");
+        tbody.innerHTML = "";
+
+        for (var i = 0; i < names.length; i++) {
+            var api = data.apis[names[i]];
+            var win = (api.windows || {})[window] || {latency: {}, error_codes: {}};
+
+            // percentiles only cover the most recent max_samples calls of the window
+            var approx = win.latency_truncated ? "~" : "";
+
+            var codes = [];
+            for (var code in win.error_codes) {
+                codes.push(code + " x" + win.error_codes[code]);
             }
+
+            Statistics.row(tbody, [
+                names[i],
+                api.total,
+                api.errors,
+                Statistics.percent(api.error_rate),
+                win.count || 0,
+                (win.qps || 0).toFixed(2),
+                Statistics.percent(win.error_rate),
+                win.latency.p50 ? approx + win.latency.p50 : "-",
+                win.latency.p95 ? approx + win.latency.p95 : "-",
+                win.latency.p99 ? approx + win.latency.p99 : "-",
+                codes.join(", "),
+                new Date(api.last_call).toLocaleString()
+            ], win.error_rate > 0);
+        }
+    },
+
+    row: function (tbody, cells, hasError) {
+        var tr = document.createElement("tr");
+        if (hasError) {
+            tr.className = "error";
         }
-    }
 
-};
\ No newline at end of file
+        for (var i = 0; i < cells.length; i++) {
+            var td = document.createElement("td");
+            td.textContent = cells[i];
+            tr.appendChild(td);
+        }
+        tbody.appendChild(tr);
+    },
+
+    percent: function (v) {
+        return ((v || 0) * 100).toFixed(2) + "%";
+    }
+};
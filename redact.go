@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	REDACTED_VALUE = "******"
+)
+
+type redactRules struct {
+	keys  []string
+	paths [][]string
+	mask  string
+
+	jsonExprs []*regexp.Regexp
+	textExprs []*regexp.Regexp
+}
+
+type Redactor struct {
+	global *redactRules
+	apis   map[string]*redactRules
+}
+
+func NewRedactor(global RedactConfig, graphConf []GraphsConfig) (redactor *Redactor, err error) {
+	redactor = &Redactor{
+		apis: make(map[string]*redactRules),
+	}
+
+	if redactor.global, err = newRedactRules(global, nil); err != nil {
+		return
+	}
+
+	for _, graph := range graphConf {
+		if len(graph.Redact.Keys) == 0 && len(graph.Redact.Paths) == 0 && graph.Redact.Mask == "" {
+			continue
+		}
+
+		var rules *redactRules
+		if rules, err = newRedactRules(graph.Redact, redactor.global); err != nil {
+			err = fmt.Errorf("bad redact config of api %s, error: %s", graph.API, err)
+			return
+		}
+		redactor.apis[graph.API] = rules
+	}
+
+	return
+}
+
+func newRedactRules(redactConf RedactConfig, parent *redactRules) (rules *redactRules, err error) {
+	rules = &redactRules{mask: redactConf.Mask}
+
+	if parent != nil {
+		rules.keys = append(rules.keys, parent.keys...)
+		rules.paths = append(rules.paths, parent.paths...)
+		if rules.mask == "" {
+			rules.mask = parent.mask
+		}
+	}
+
+	if rules.mask == "" {
+		rules.mask = REDACTED_VALUE
+	}
+
+	for _, key := range redactConf.Keys {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		if _, err = path.Match(key, ""); err != nil {
+			err = fmt.Errorf("bad redact key pattern %s, error: %s", key, err)
+			return
+		}
+		rules.keys = append(rules.keys, key)
+	}
+
+	for _, jsonPath := range redactConf.Paths {
+		jsonPath = strings.TrimPrefix(strings.TrimSpace(jsonPath), "$.")
+		if jsonPath == "" {
+			continue
+		}
+		rules.paths = append(rules.paths, strings.Split(jsonPath, "."))
+	}
+
+	textKeys := map[string]bool{}
+	for _, key := range rules.keys {
+		textKeys[key] = true
+	}
+	for _, segments := range rules.paths {
+		if last := segments[len(segments)-1]; last != "*" {
+			textKeys[strings.ToLower(last)] = true
+		}
+	}
+
+	for key := range textKeys {
+		expr := strings.Replace(regexp.QuoteMeta(key), `\*`, `[^"=&\s]*`, -1)
+		expr = strings.Replace(expr, `\?`, `[^"=&\s]`, -1)
+		rules.jsonExprs = append(rules.jsonExprs, regexp.MustCompile(`(?i)("`+expr+`"\s*:\s*)("(?:[^"\\]|\\.)*"|[^,}\]\s]+)`))
+		rules.textExprs = append(rules.textExprs, regexp.MustCompile(`(?i)(\b`+expr+`=)([^&\s"]+)`))
+	}
+
+	return
+}
+
+func (p *redactRules) isEmpty() bool {
+	return len(p.keys) == 0 && len(p.paths) == 0
+}
+
+func (p *redactRules) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range p.keys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *redactRules) matchPath(current []string) bool {
+	for _, segments := range p.paths {
+		if len(segments) != len(current) {
+			continue
+		}
+
+		matched := true
+		for i, segment := range segments {
+			if segment != "*" && !strings.EqualFold(segment, current[i]) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *redactRules) redact(v interface{}, current []string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, item := range val {
+			itemPath := append(current[:len(current):len(current)], key)
+			if p.matchKey(key) || p.matchPath(itemPath) {
+				val[key] = p.mask
+			} else {
+				val[key] = p.redact(item, itemPath)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			itemPath := append(current[:len(current):len(current)], strconv.Itoa(i))
+			if p.matchPath(itemPath) {
+				val[i] = p.mask
+			} else {
+				val[i] = p.redact(item, itemPath)
+			}
+		}
+	}
+	return v
+}
+
+func (p *Redactor) rules(apiName string) *redactRules {
+	if rules, exist := p.apis[apiName]; exist {
+		return rules
+	}
+	return p.global
+}
+
+func (p *Redactor) Redact(apiName string, v interface{}) interface{} {
+	if p == nil {
+		return v
+	}
+
+	rules := p.rules(apiName)
+	if rules.isEmpty() {
+		return v
+	}
+
+	return rules.redact(v, nil)
+}
+
+func (p *Redactor) RedactJSON(apiName string, data []byte) (v interface{}, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&v); err != nil {
+		return
+	}
+
+	v = p.Redact(apiName, v)
+	return
+}
+
+func (p *Redactor) RedactText(apiName string, text string) string {
+	if p == nil {
+		return text
+	}
+
+	rules := p.rules(apiName)
+
+	for _, expr := range rules.jsonExprs {
+		text = expr.ReplaceAllString(text, "${1}"+strconv.Quote(rules.mask))
+	}
+
+	for _, expr := range rules.textExprs {
+		text = expr.ReplaceAllString(text, "${1}"+rules.mask)
+	}
+
+	return text
+}
+
+func (p *Redactor) RedactError(apiName string, err error) string {
+	if err == nil {
+		return ""
+	}
+	return p.RedactText(apiName, err.Error())
+}
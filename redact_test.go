@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	global := RedactConfig{Keys: []string{"password", "*token"}, Paths: []string{"$.user.phone", "cards.*.number"}}
+	graphs := []GraphsConfig{
+		{API: "masked", Redact: RedactConfig{Mask: "[x]"}},
+		{API: "extra", Redact: RedactConfig{Keys: []string{"secret"}}},
+	}
+
+	redactor, err := NewRedactor(global, graphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		api    string
+		input  string
+		output string
+	}{
+		{api: "", input: `{"name":"a","password":"p"}`, output: `{"name":"a","password":"******"}`},
+		{api: "", input: `{"PassWord":1}`, output: `{"PassWord":"******"}`},
+		{api: "", input: `{"access_token":"t","token_type":"bearer"}`, output: `{"access_token":"******","token_type":"bearer"}`},
+		{api: "", input: `{"nested":{"list":[{"password":"p"}]}}`, output: `{"nested":{"list":[{"password":"******"}]}}`},
+		{api: "", input: `{"user":{"phone":"1","name":"a"},"phone":"2"}`, output: `{"phone":"2","user":{"name":"a","phone":"******"}}`},
+		{api: "", input: `{"cards":[{"number":"1","cvv":"2"},{"number":"3"}]}`, output: `{"cards":[{"cvv":"2","number":"******"},{"number":"******"}]}`},
+		{api: "", input: `{"secret":"s"}`, output: `{"secret":"s"}`},
+		{api: "", input: `[1,2]`, output: `[1,2]`},
+		{api: "masked", input: `{"password":"p"}`, output: `{"password":"[x]"}`},
+		{api: "extra", input: `{"password":"p","secret":"s"}`, output: `{"password":"******","secret":"******"}`},
+		{api: "unknown", input: `{"password":"p","secret":"s"}`, output: `{"password":"******","secret":"s"}`},
+	}
+
+	for _, test := range tests {
+		v, err := redactor.RedactJSON(test.api, []byte(test.input))
+		if err != nil {
+			t.Errorf("RedactJSON(%q, %s) failed: %s", test.api, test.input, err)
+			continue
+		}
+
+		output, _ := json.Marshal(v)
+		if string(output) != test.output {
+			t.Errorf("RedactJSON(%q, %s) = %s, want %s", test.api, test.input, output, test.output)
+		}
+	}
+}
+
+func TestRedactText(t *testing.T) {
+	redactor, err := NewRedactor(RedactConfig{Keys: []string{"password", "*token"}, Paths: []string{"user.phone"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{input: `login failed`, output: `login failed`},
+		{input: `bad request {"password": "p\"q", "name":"a"}`, output: `bad request {"password": "******", "name":"a"}`},
+		{input: `{"Access_Token":12345}`, output: `{"Access_Token":"******"}`},
+		{input: `GET /login?password=p&name=a`, output: `GET /login?password=******&name=a`},
+		{input: `refresh_token=abc def`, output: `refresh_token=****** def`},
+		{input: `{"phone":"1"}`, output: `{"phone":"******"}`},
+		{input: `passwords=p`, output: `passwords=p`},
+	}
+
+	for _, test := range tests {
+		if output := redactor.RedactText("", test.input); output != test.output {
+			t.Errorf("RedactText(%q) = %q, want %q", test.input, output, test.output)
+		}
+	}
+}
+
+func TestNewRedactorBadPattern(t *testing.T) {
+	if _, err := NewRedactor(RedactConfig{Keys: []string{"[bad"}}, nil); err == nil {
+		t.Error("NewRedactor with a bad key pattern should fail")
+	}
+}
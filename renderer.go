@@ -16,6 +16,7 @@ type APIRenderData struct {
 	IsMulti  bool
 	Name     string
 	Response APIResponse
+	Alias    string
 }
 
 type RenderData struct {
@@ -23,6 +24,11 @@ type RenderData struct {
 	Vars map[string]interface{}
 }
 
+type RenderOptions struct {
+	Aliases map[string]string
+	Fields  *FieldSelection
+}
+
 type APIResponseRenderer struct {
 	apiTemplate map[string]string
 	template.Template
@@ -207,15 +213,27 @@ func (p *APIResponseRenderer) AddTemplate(name, tpl string) (err error) {
 }
 
 func (p *APIResponseRenderer) Render(isMulti bool, response map[string]APIResponse) (text string, err error) {
+	return p.RenderWithOptions(isMulti, response, RenderOptions{})
+}
+
+func (p *APIResponseRenderer) RenderWithOptions(isMulti bool, response map[string]APIResponse, opts RenderOptions) (text string, err error) {
 	output := map[string]string{}
 
-	for api, response := range response {
+	for alias, response := range response {
+
+		api := alias
+		if name, exist := opts.Aliases[alias]; exist {
+			api = name
+		}
+
+		response = opts.Fields.ProjectResponse(response)
 
 		renderData := RenderData{
 			API: APIRenderData{
 				false,
 				api,
 				response,
+				alias,
 			},
 			Vars: p.Variables,
 		}
@@ -235,7 +253,7 @@ func (p *APIResponseRenderer) Render(isMulti bool, response map[string]APIRespon
 			return
 		}
 
-		output[api] = buf.String()
+		output[alias] = buf.String()
 	}
 
 	var buf bytes.Buffer
@@ -251,6 +269,7 @@ func (p *APIResponseRenderer) Render(isMulti bool, response map[string]APIRespon
 			true,
 			"",
 			multiResponse,
+			"",
 		},
 		Vars: p.Variables,
 	}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	API_ATTEMPTS_HEADER = "X-Api-Attempts"
+	CTX_ATTEMPT         = "attempt"
+
+	DEFAULT_RETRY_MAX_ATTEMPTS = 3
+	DEFAULT_RETRY_BACKOFF      = 100
+	DEFAULT_RETRY_MAX_BACKOFF  = 1000
+)
+
+var DEFAULT_RETRY_ON = []string{"INLET_API:5", "INLET_HTTP"}
+
+type retryCondition struct {
+	namespace string
+	code      int64
+	anyCode   bool
+}
+
+type RetryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	hedgeDelay  time.Duration
+	conditions  []retryCondition
+}
+
+func NewRetryPolicy(retryConf RetryConfig) (policy *RetryPolicy, err error) {
+	policy = &RetryPolicy{
+		maxAttempts: retryConf.MaxAttempts,
+		backoff:     time.Duration(retryConf.Backoff) * time.Millisecond,
+		maxBackoff:  time.Duration(retryConf.MaxBackoff) * time.Millisecond,
+		hedgeDelay:  time.Duration(retryConf.HedgeDelay) * time.Millisecond,
+	}
+
+	if policy.maxAttempts <= 0 {
+		policy.maxAttempts = DEFAULT_RETRY_MAX_ATTEMPTS
+	}
+
+	if policy.backoff <= 0 {
+		policy.backoff = DEFAULT_RETRY_BACKOFF * time.Millisecond
+	}
+
+	if policy.maxBackoff < policy.backoff {
+		policy.maxBackoff = DEFAULT_RETRY_MAX_BACKOFF * time.Millisecond
+		if policy.maxBackoff < policy.backoff {
+			policy.maxBackoff = policy.backoff
+		}
+	}
+
+	retryOn := retryConf.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = DEFAULT_RETRY_ON
+	}
+
+	for _, value := range retryOn {
+		condition := retryCondition{}
+
+		parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+		if condition.namespace = parts[0]; condition.namespace == "" {
+			err = fmt.Errorf("bad retry condition: %s", value)
+			return
+		}
+
+		if len(parts) == 1 {
+			condition.anyCode = true
+		} else if condition.code, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			err = fmt.Errorf("bad retry condition: %s", value)
+			return
+		}
+
+		policy.conditions = append(policy.conditions, condition)
+	}
+
+	return
+}
+
+func (p *RetryPolicy) Retryable(resp APIResponse) bool {
+	if resp.Code == 0 {
+		return false
+	}
+
+	for _, condition := range p.conditions {
+		if condition.namespace == resp.ErrorNamespace && (condition.anyCode || condition.code == int64(resp.Code)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *RetryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > p.maxBackoff {
+		return p.maxBackoff
+	}
+	return backoff
+}
+
+func (p *APIDispatcher) callWithPolicy(parent *http.Request, call APICall, policy *RetryPolicy) (resp APIResponse, attempts int) {
+	results := make(chan APIResponse, policy.maxAttempts)
+
+	pending := 0
+	launch := func() {
+		attempts++
+		pending++
+		go func(attempt int) {
+			results <- p.call(parent, call, attempt)
+		}(attempts)
+	}
+
+	launch()
+
+	backoff := policy.backoff
+	for {
+		var hedge <-chan time.Time
+		var hedgeTimer *time.Timer
+		if policy.hedgeDelay > 0 && attempts < policy.maxAttempts {
+			hedgeTimer = time.NewTimer(policy.hedgeDelay)
+			hedge = hedgeTimer.C
+		}
+
+		select {
+		case resp = <-results:
+			pending--
+		case <-hedge:
+			launch()
+			continue
+		}
+
+		if hedgeTimer != nil {
+			hedgeTimer.Stop()
+		}
+
+		if !policy.Retryable(resp) {
+			return
+		}
+
+		if pending > 0 {
+			continue
+		}
+
+		if attempts >= policy.maxAttempts {
+			return
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-parent.Context().Done():
+			timer.Stop()
+			return
+		}
+
+		backoff = policy.nextBackoff(backoff)
+		launch()
+	}
+}
+
+func isRetryCall(r *http.Request) bool {
+	_, exist := retryPolicies[requestAPIName(r)]
+	return exist && !isMultiCall(r)
+}
+
+func retryCallHandle(w http.ResponseWriter, r *http.Request) {
+	apiName := requestAPIName(r)
+
+	if _, err := apiGraphProvider.Resolve(r, apiName); err != nil {
+		errorResponseHandler(err, w, r)
+		return
+	}
+
+	body, e := readRequestBody(r)
+	if e != nil {
+		errorResponseHandler(e, w, r)
+		return
+	}
+
+	resp, attempts := apiDispatcher.CallAttempts(r, APICall{
+		Name:    apiName,
+		API:     apiName,
+		Body:    body,
+		Timeout: parseTimeoutHeader(r, API_CALL_TIMEOUT),
+	})
+
+	responses := map[string]APIResponse{apiName: resp}
+	if ctx := apiRequestContext(r); ctx != nil {
+		ctx.Responses = responses
+	}
+
+	w.Header().Set(API_ATTEMPTS_HEADER, strconv.Itoa(attempts))
+	writeAPIResponses(false, responses, w, r)
+}
+
+func newRetryPolicies(graphConf []GraphsConfig) (policies map[string]*RetryPolicy, err error) {
+	policies = make(map[string]*RetryPolicy)
+
+	for _, graph := range graphConf {
+		if graph.Retry == nil {
+			continue
+		}
+
+		if !graph.SafeToRetry {
+			err = fmt.Errorf("bad retry policy of api %s, error: only apis marked safe_to_retry could be retried", graph.API)
+			return
+		}
+
+		if policies[graph.API], err = NewRetryPolicy(*graph.Retry); err != nil {
+			err = fmt.Errorf("bad retry policy of api %s, error: %s", graph.API, err)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRetryPolicies(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph GraphsConfig
+		retry bool
+		bad   bool
+	}{
+		{name: "no retry", graph: GraphsConfig{API: "a"}},
+		{name: "safe to retry", graph: GraphsConfig{API: "a", SafeToRetry: true, Retry: &RetryConfig{}}, retry: true},
+		{name: "not safe to retry", graph: GraphsConfig{API: "a", Retry: &RetryConfig{}}, bad: true},
+		{name: "idempotency key only", graph: GraphsConfig{API: "api.order.create", Idempotent: true, Retry: &RetryConfig{}}, bad: true},
+		{name: "bad condition", graph: GraphsConfig{API: "a", SafeToRetry: true, Retry: &RetryConfig{RetryOn: []string{":5"}}}, bad: true},
+		{name: "bad code", graph: GraphsConfig{API: "a", SafeToRetry: true, Retry: &RetryConfig{RetryOn: []string{"INLET_API:x"}}}, bad: true},
+	}
+
+	for _, test := range tests {
+		policies, err := newRetryPolicies([]GraphsConfig{test.graph})
+		if test.bad {
+			if err == nil {
+				t.Errorf("%s: newRetryPolicies should fail", test.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: newRetryPolicies failed: %s", test.name, err)
+		} else if _, exist := policies[test.graph.API]; exist != test.retry {
+			t.Errorf("%s: retry policy exist = %v, want %v", test.name, exist, test.retry)
+		}
+	}
+}
+
+func TestNewRetryPolicyDefaults(t *testing.T) {
+	tests := []struct {
+		retryConf   RetryConfig
+		maxAttempts int
+		backoff     time.Duration
+		maxBackoff  time.Duration
+	}{
+		{retryConf: RetryConfig{}, maxAttempts: 3, backoff: 100 * time.Millisecond, maxBackoff: time.Second},
+		{retryConf: RetryConfig{MaxAttempts: 5, Backoff: 200, MaxBackoff: 400}, maxAttempts: 5, backoff: 200 * time.Millisecond, maxBackoff: 400 * time.Millisecond},
+		{retryConf: RetryConfig{Backoff: 2000}, maxAttempts: 3, backoff: 2 * time.Second, maxBackoff: 2 * time.Second},
+		{retryConf: RetryConfig{Backoff: 500, MaxBackoff: 100}, maxAttempts: 3, backoff: 500 * time.Millisecond, maxBackoff: time.Second},
+	}
+
+	for _, test := range tests {
+		policy, err := NewRetryPolicy(test.retryConf)
+		if err != nil {
+			t.Errorf("NewRetryPolicy(%+v) failed: %s", test.retryConf, err)
+			continue
+		}
+
+		if policy.maxAttempts != test.maxAttempts || policy.backoff != test.backoff || policy.maxBackoff != test.maxBackoff {
+			t.Errorf("NewRetryPolicy(%+v) = %d, %s, %s, want %d, %s, %s", test.retryConf,
+				policy.maxAttempts, policy.backoff, policy.maxBackoff, test.maxAttempts, test.backoff, test.maxBackoff)
+		}
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy, err := NewRetryPolicy(RetryConfig{RetryOn: []string{"INLET_API:5", " INLET_HTTP "}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		resp      APIResponse
+		retryable bool
+	}{
+		{resp: APIResponse{}, retryable: false},
+		{resp: APIResponse{Code: 5, ErrorNamespace: "INLET_API"}, retryable: true},
+		{resp: APIResponse{Code: 6, ErrorNamespace: "INLET_API"}, retryable: false},
+		{resp: APIResponse{Code: 1, ErrorNamespace: "INLET_HTTP"}, retryable: true},
+		{resp: APIResponse{Code: 5, ErrorNamespace: "TASK"}, retryable: false},
+	}
+
+	for _, test := range tests {
+		if retryable := policy.Retryable(test.resp); retryable != test.retryable {
+			t.Errorf("Retryable(%+v) = %v, want %v", test.resp, retryable, test.retryable)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gogap/errors"
+	"github.com/gogap/logs"
+)
+
+const (
+	DEFAULT_SHUTDOWN_TIMEOUT = 30
+
+	DRAIN_POLL_INTERVAL = 100 * time.Millisecond
+)
+
+type DrainTracker struct {
+	sync.Mutex
+
+	draining bool
+	next     uint64
+	inflight map[uint64]string
+	hooks    []func()
+}
+
+func NewDrainTracker() *DrainTracker {
+	return &DrainTracker{
+		inflight: make(map[uint64]string),
+	}
+}
+
+func (p *DrainTracker) Accept(apiName string) (done func(), ok bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.draining {
+		return nil, false
+	}
+
+	return p.track(apiName), true
+}
+
+func (p *DrainTracker) Track(apiName string) (done func()) {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.track(apiName)
+}
+
+func (p *DrainTracker) track(apiName string) func() {
+	p.next++
+	id := p.next
+	p.inflight[id] = apiName
+
+	return func() {
+		p.Lock()
+		defer p.Unlock()
+
+		delete(p.inflight, id)
+	}
+}
+
+func (p *DrainTracker) Draining() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.draining
+}
+
+func (p *DrainTracker) OnDrain(hook func()) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.hooks = append(p.hooks, hook)
+}
+
+func (p *DrainTracker) Drain(timeout time.Duration) (abandoned map[string]int) {
+	p.Lock()
+	p.draining = true
+	hooks := p.hooks
+	p.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		p.Lock()
+		pending := len(p.inflight)
+		if pending == 0 || !time.Now().Before(deadline) {
+			abandoned = make(map[string]int)
+			for _, apiName := range p.inflight {
+				abandoned[apiName]++
+			}
+			p.Unlock()
+			return
+		}
+		p.Unlock()
+
+		time.Sleep(DRAIN_POLL_INTERVAL)
+	}
+}
+
+func drainHandle(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiName := ""
+		if !isMultiCall(r) {
+			apiName = requestAPIName(r)
+		}
+
+		done, ok := drainTracker.Accept(apiName)
+		if !ok {
+			w.Header().Set("Connection", "close")
+			writeResponseWithStatusCode(newErrorAPIResponse(ERR_SERVER_SHUTTING_DOWN.New(errors.Params{"api": apiName})), w, r, http.StatusServiceUnavailable)
+			return
+		}
+		defer done()
+
+		handler(w, r)
+	}
+}
+
+func pingHandle() (int, string) {
+	if drainTracker.Draining() {
+		return http.StatusServiceUnavailable, "draining"
+	}
+	return http.StatusOK, "pong"
+}
+
+// the inlet owns SIGTERM and SIGINT and keeps the process up until the drain
+// is over, returning when the spirit stops by itself
+func runUntilShutdown(run func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		run()
+	}()
+
+	var sig os.Signal
+	select {
+	case <-stopped:
+		return
+	case sig = <-signals:
+	}
+
+	// a second signal falls back to the default action and ends the process at once
+	signal.Stop(signals)
+
+	logs.Info("received signal", sig, "draining in-flight api calls, timeout:", conf.HTTP.Shutdown.Timeout, "seconds")
+
+	// inlet_http does not expose its listener, it stays open and the drain
+	// tracker refuses the calls arriving on it
+
+	abandoned := drainTracker.Drain(time.Duration(conf.HTTP.Shutdown.Timeout) * time.Second)
+	if len(abandoned) == 0 {
+		logs.Info("all in-flight api calls finished, shutting down")
+	}
+
+	for apiName, count := range abandoned {
+		logs.Warn("shutdown deadline reached, abandoned api calls, api:", apiName, "count:", count)
+	}
+}
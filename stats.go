@@ -0,0 +1,406 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_STATS_PATH        = "/_stats"
+	DEFAULT_STATS_HEADER      = "X-Stats-Token"
+	DEFAULT_STATS_MAX_SAMPLES = 10000
+	DEFAULT_STATS_DASHBOARD   = "public/stat"
+)
+
+var (
+	DefaultStatsWindows = []string{"1m", "5m", "15m"}
+)
+
+type apiStatSample struct {
+	At      time.Time
+	Latency time.Duration
+}
+
+type apiStatBucket struct {
+	second     int64
+	count      int
+	errors     int
+	errorCodes map[string]int
+}
+
+type apiStat struct {
+	Total      uint64
+	Errors     uint64
+	Shed       uint64
+	LastCall   time.Time
+	ErrorCodes map[string]uint64
+
+	buckets []apiStatBucket
+	samples []apiStatSample
+	next    int
+}
+
+type APIStatsWindow struct {
+	Count            int               `json:"count"`
+	Errors           int               `json:"errors"`
+	ErrorRate        float64           `json:"error_rate"`
+	ErrorCodes       map[string]int    `json:"error_codes"`
+	QPS              float64           `json:"qps"`
+	Latency          map[string]string `json:"latency"`
+	LatencyMS        map[string]int64  `json:"latency_ms"`
+	LatencySamples   int               `json:"latency_samples"`
+	LatencyTruncated bool              `json:"latency_truncated"`
+}
+
+type APIStatsSnapshot struct {
+	Total      uint64                    `json:"total"`
+	Errors     uint64                    `json:"errors"`
+	ErrorRate  float64                   `json:"error_rate"`
+	ErrorCodes map[string]uint64         `json:"error_codes"`
+	Shed       uint64                    `json:"shed"`
+	LastCall   time.Time                 `json:"last_call"`
+	Windows    map[string]APIStatsWindow `json:"windows"`
+}
+
+type StatsSnapshot struct {
+	StartTime time.Time                   `json:"start_time"`
+	Uptime    string                      `json:"uptime"`
+	Windows   []string                    `json:"windows"`
+	Shed      uint64                      `json:"shed"`
+	APIs      map[string]APIStatsSnapshot `json:"apis"`
+}
+
+type APIStatsCollector struct {
+	sync.Mutex
+
+	startTime   time.Time
+	windowNames []string
+	windows     []time.Duration
+	maxSamples  int
+	maxSeconds  int64
+	apis        map[string]*apiStat
+	shed        uint64
+}
+
+func NewAPIStatsCollector(windows []string, maxSamples int) (collector *APIStatsCollector, err error) {
+	if maxSamples <= 0 {
+		maxSamples = DEFAULT_STATS_MAX_SAMPLES
+	}
+
+	if len(windows) == 0 {
+		windows = DefaultStatsWindows
+	}
+
+	collector = &APIStatsCollector{
+		startTime:  time.Now(),
+		maxSamples: maxSamples,
+		apis:       make(map[string]*apiStat),
+	}
+
+	for _, window := range windows {
+		var duration time.Duration
+		if duration, err = time.ParseDuration(window); err != nil {
+			return
+		} else if duration <= 0 {
+			err = fmt.Errorf("stats window should be greater than zero, window: %s", window)
+			return
+		}
+		collector.windowNames = append(collector.windowNames, window)
+		collector.windows = append(collector.windows, duration)
+
+		if seconds := windowSeconds(duration); seconds > collector.maxSeconds {
+			collector.maxSeconds = seconds
+		}
+	}
+
+	return
+}
+
+func (p *APIStatsCollector) Record(apiName string, latency time.Duration, resp APIResponse) {
+	if apiName == "" {
+		return
+	}
+
+	errCode := ""
+	if resp.Code != 0 {
+		errCode = fmt.Sprintf("%s:%d", resp.ErrorNamespace, resp.Code)
+	}
+
+	now := time.Now()
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.apiStat(apiName).record(now, latency, errCode, p.maxSamples)
+}
+
+func (p *apiStat) record(now time.Time, latency time.Duration, errCode string, maxSamples int) {
+	p.Total++
+	p.LastCall = now
+	if errCode != "" {
+		p.Errors++
+		p.ErrorCodes[errCode]++
+	}
+
+	second := now.Unix()
+	bucket := &p.buckets[second%int64(len(p.buckets))]
+	if bucket.second != second {
+		*bucket = apiStatBucket{second: second}
+	}
+
+	bucket.count++
+	if errCode != "" {
+		bucket.errors++
+		if bucket.errorCodes == nil {
+			bucket.errorCodes = make(map[string]int)
+		}
+		bucket.errorCodes[errCode]++
+	}
+
+	sample := apiStatSample{At: now, Latency: latency}
+	if len(p.samples) < maxSamples {
+		p.samples = append(p.samples, sample)
+	} else {
+		p.samples[p.next] = sample
+		p.next = (p.next + 1) % maxSamples
+	}
+}
+
+func (p *APIStatsCollector) RecordShed(apiName string) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.shed++
+	if apiName != "" {
+		p.apiStat(apiName).Shed++
+	}
+}
+
+func (p *APIStatsCollector) apiStat(apiName string) *apiStat {
+	stat, exist := p.apis[apiName]
+	if !exist {
+		stat = &apiStat{
+			ErrorCodes: make(map[string]uint64),
+			buckets:    make([]apiStatBucket, p.maxSeconds),
+		}
+		p.apis[apiName] = stat
+	}
+	return stat
+}
+
+func (p *APIStatsCollector) Snapshot() StatsSnapshot {
+	now := time.Now()
+
+	snapshot := StatsSnapshot{
+		StartTime: p.startTime,
+		Uptime:    now.Sub(p.startTime).String(),
+		Windows:   p.windowNames,
+		APIs:      make(map[string]APIStatsSnapshot),
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	snapshot.Shed = p.shed
+
+	for apiName, stat := range p.apis {
+		apiSnapshot := APIStatsSnapshot{
+			Total:      stat.Total,
+			Errors:     stat.Errors,
+			ErrorRate:  rate(int64(stat.Errors), int64(stat.Total)),
+			ErrorCodes: make(map[string]uint64),
+			Shed:       stat.Shed,
+			LastCall:   stat.LastCall,
+			Windows:    make(map[string]APIStatsWindow),
+		}
+
+		for code, count := range stat.ErrorCodes {
+			apiSnapshot.ErrorCodes[code] = count
+		}
+
+		for i, window := range p.windows {
+			apiSnapshot.Windows[p.windowNames[i]] = stat.window(now, window, p.maxSamples)
+		}
+
+		snapshot.APIs[apiName] = apiSnapshot
+	}
+
+	return snapshot
+}
+
+func windowSeconds(window time.Duration) int64 {
+	return int64((window + time.Second - 1) / time.Second)
+}
+
+func (p *apiStat) window(now time.Time, window time.Duration, maxSamples int) APIStatsWindow {
+	stats := APIStatsWindow{
+		ErrorCodes: make(map[string]int),
+		Latency:    make(map[string]string),
+		LatencyMS:  make(map[string]int64),
+	}
+
+	second, seconds := now.Unix(), windowSeconds(window)
+	for _, bucket := range p.buckets {
+		if bucket.count == 0 || second-bucket.second >= seconds {
+			continue
+		}
+
+		stats.Count += bucket.count
+		stats.Errors += bucket.errors
+		for code, count := range bucket.errorCodes {
+			stats.ErrorCodes[code] += count
+		}
+	}
+
+	since := now.Add(-window)
+
+	latencies := []time.Duration{}
+	for _, sample := range p.samples {
+		if !sample.At.Before(since) {
+			latencies = append(latencies, sample.Latency)
+		}
+	}
+
+	stats.LatencySamples = len(latencies)
+	stats.LatencyTruncated = len(p.samples) >= maxSamples && stats.Count > len(latencies)
+
+	stats.ErrorRate = rate(int64(stats.Errors), int64(stats.Count))
+	stats.QPS = float64(stats.Count) / window.Seconds()
+
+	sort.Sort(durations(latencies))
+
+	for _, percentile := range []int{50, 95, 99} {
+		name := fmt.Sprintf("p%d", percentile)
+		value := percentileOf(latencies, percentile)
+		stats.Latency[name] = value.String()
+		stats.LatencyMS[name] = int64(value / time.Millisecond)
+	}
+
+	return stats
+}
+
+type durations []time.Duration
+
+func (p durations) Len() int           { return len(p) }
+func (p durations) Less(i, j int) bool { return p[i] < p[j] }
+func (p durations) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func percentileOf(sorted []time.Duration, percentile int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	index := (len(sorted)*percentile+99)/100 - 1
+	if index < 0 {
+		index = 0
+	} else if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+
+	return sorted[index]
+}
+
+func rate(count, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+func (p *StatsConfig) authorized(r *http.Request) bool {
+	return tokenAuthorized(r, p.Header, p.Token)
+}
+
+func tokenAuthorized(r *http.Request, header, expected string) bool {
+	token := r.Header.Get(header)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+
+	return tokenMatched(token, expected)
+}
+
+func tokenMatched(token, expected string) bool {
+	if expected == "" {
+		return true
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+func statsHandle(w http.ResponseWriter, r *http.Request) {
+	if !conf.HTTP.Stats.authorized(r) {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_STATS_UNAUTHORIZED.New()), w, r, http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	writeResponse(statsCollector.Snapshot(), w, r)
+}
+
+func statsDashboardHandle(w http.ResponseWriter, r *http.Request) {
+	prefix := conf.HTTP.PATH + conf.HTTP.Stats.Path + "/dashboard"
+	if r.URL.Path == prefix {
+		http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	dir := filepath.Clean(conf.HTTP.Stats.Dashboard)
+
+	if page := strings.TrimPrefix(r.URL.Path, prefix); page != "/" && page != "/index.html" {
+		http.StripPrefix(prefix, http.FileServer(http.Dir(dir))).ServeHTTP(w, r)
+		return
+	}
+
+	if !conf.HTTP.Stats.authorized(r) {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_STATS_UNAUTHORIZED.New()), w, r, http.StatusUnauthorized)
+		return
+	}
+
+	index, err := template.ParseFiles(filepath.Join(dir, "index.html"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	index.Execute(w, map[string]string{"Header": conf.HTTP.Stats.Header})
+}
+
+func recordAPIStats(r *http.Request, responses map[string]APIResponse) {
+	if statsCollector == nil {
+		return
+	}
+
+	ctx := apiRequestContext(r)
+	if ctx == nil {
+		return
+	}
+
+	latency := time.Now().Sub(ctx.StartTime)
+	for apiName, resp := range responses {
+		if apiName = strings.TrimSpace(apiName); apiGraphProvider.IsAPIExist(apiName) {
+			statsCollector.Record(apiName, latency, resp)
+		}
+	}
+}
+
+func recordShedStats(apiName string) {
+	if statsCollector == nil {
+		return
+	}
+
+	if apiName = strings.TrimSpace(apiName); !apiGraphProvider.IsAPIExist(apiName) {
+		apiName = ""
+	}
+
+	statsCollector.RecordShed(apiName)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentileOf(t *testing.T) {
+	ten := []time.Duration{}
+	for i := 1; i <= 10; i++ {
+		ten = append(ten, time.Duration(i)*time.Millisecond)
+	}
+
+	tests := []struct {
+		sorted     []time.Duration
+		percentile int
+		value      time.Duration
+	}{
+		{sorted: nil, percentile: 50, value: 0},
+		{sorted: []time.Duration{time.Second}, percentile: 50, value: time.Second},
+		{sorted: []time.Duration{time.Second}, percentile: 99, value: time.Second},
+		{sorted: []time.Duration{1, 2}, percentile: 50, value: 1},
+		{sorted: []time.Duration{1, 2}, percentile: 51, value: 2},
+		{sorted: ten, percentile: 0, value: 1 * time.Millisecond},
+		{sorted: ten, percentile: 10, value: 1 * time.Millisecond},
+		{sorted: ten, percentile: 50, value: 5 * time.Millisecond},
+		{sorted: ten, percentile: 95, value: 10 * time.Millisecond},
+		{sorted: ten, percentile: 99, value: 10 * time.Millisecond},
+		{sorted: ten, percentile: 100, value: 10 * time.Millisecond},
+		{sorted: ten, percentile: 120, value: 10 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		if value := percentileOf(test.sorted, test.percentile); value != test.value {
+			t.Errorf("percentileOf(%v, %d) = %s, want %s", test.sorted, test.percentile, value, test.value)
+		}
+	}
+}
+
+func TestAPIStatWindow(t *testing.T) {
+	now := time.Unix(1000000, 0)
+
+	tests := []struct {
+		name       string
+		maxSamples int
+		calls      []time.Duration
+		window     time.Duration
+		count      int
+		samples    int
+		truncated  bool
+	}{
+		{name: "empty", maxSamples: 10, window: time.Minute},
+		{name: "within window", maxSamples: 10, calls: []time.Duration{0, 10 * time.Second, 59 * time.Second}, window: time.Minute, count: 3, samples: 3},
+		{name: "outside window", maxSamples: 10, calls: []time.Duration{0, 2 * time.Minute, time.Hour}, window: time.Minute, count: 1, samples: 1},
+		{name: "truncated", maxSamples: 2, calls: []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second}, window: time.Minute, count: 4, samples: 2, truncated: true},
+		{name: "full but not truncated", maxSamples: 2, calls: []time.Duration{0, time.Second, 2 * time.Minute}, window: time.Minute, count: 2, samples: 2},
+	}
+
+	for _, test := range tests {
+		collector, err := NewAPIStatsCollector([]string{"1m", "1h"}, test.maxSamples)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stat := collector.apiStat("api")
+		for i := len(test.calls) - 1; i >= 0; i-- {
+			at := now.Add(-test.calls[i])
+			stat.record(at, time.Millisecond, "", test.maxSamples)
+		}
+
+		window := stat.window(now, test.window, test.maxSamples)
+		if window.Count != test.count || window.LatencySamples != test.samples || window.LatencyTruncated != test.truncated {
+			t.Errorf("%s: count %d, samples %d, truncated %v, want %d, %d, %v", test.name,
+				window.Count, window.LatencySamples, window.LatencyTruncated, test.count, test.samples, test.truncated)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogap/errors"
+	"github.com/gogap/logs"
+	"github.com/gogap/spirit"
+)
+
+const (
+	DEFAULT_STREAM_PATH        = "/stream"
+	DEFAULT_STREAM_HEARTBEAT   = 15
+	DEFAULT_STREAM_MAX_EVENTS  = 256
+	DEFAULT_STREAM_TTL         = 60
+	DEFAULT_STREAM_MAX_STREAMS = 1000
+
+	STREAM_ID_HEADER = "X-Api-Stream-Id"
+
+	STREAM_RESULT_GRACE = time.Second
+
+	STREAM_EVENT_STREAM   = "stream"
+	STREAM_EVENT_PROGRESS = "progress"
+	STREAM_EVENT_RESULT   = "result"
+)
+
+type streamEvent struct {
+	Id    int
+	Event string
+	Data  string
+}
+
+type eventStream struct {
+	sync.Mutex
+
+	id        string
+	events    []streamEvent
+	nextId    int
+	done      bool
+	deadline  time.Time
+	expiresAt time.Time
+	notify    chan struct{}
+}
+
+func (p *eventStream) publish(event, data string, maxEvents int) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.done {
+		return
+	}
+
+	p.nextId++
+	p.events = append(p.events, streamEvent{Id: p.nextId, Event: event, Data: data})
+	if len(p.events) > maxEvents {
+		p.events = p.events[len(p.events)-maxEvents:]
+	}
+
+	close(p.notify)
+	p.notify = make(chan struct{})
+}
+
+func (p *eventStream) finish(ttl time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.done = true
+	p.expiresAt = time.Now().Add(ttl)
+
+	close(p.notify)
+	p.notify = make(chan struct{})
+}
+
+func (p *eventStream) since(lastId int) (events []streamEvent, done bool, wait <-chan struct{}) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, event := range p.events {
+		if event.Id > lastId {
+			events = append(events, event)
+		}
+	}
+
+	return events, p.done, p.notify
+}
+
+type StreamHub struct {
+	sync.Mutex
+
+	streams    map[string]*eventStream
+	maxEvents  int
+	maxStreams int
+	ttl        time.Duration
+}
+
+func NewStreamHub(streamConf StreamConfig) *StreamHub {
+	return &StreamHub{
+		streams:    make(map[string]*eventStream),
+		maxEvents:  streamConf.MaxEvents,
+		maxStreams: streamConf.MaxStreams,
+		ttl:        time.Duration(streamConf.TTL) * time.Second,
+	}
+}
+
+func (p *StreamHub) Open(timeout time.Duration) (stream *eventStream, ok bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.streams) >= p.maxStreams {
+		return nil, false
+	}
+
+	stream = &eventStream{
+		id:       newRequestId(),
+		deadline: time.Now().Add(timeout),
+		notify:   make(chan struct{}),
+	}
+	p.streams[stream.id] = stream
+
+	return stream, true
+}
+
+func (p *StreamHub) Get(id string) *eventStream {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.streams[id]
+}
+
+func (p *StreamHub) Publish(id, event string, v interface{}) bool {
+	stream := p.Get(id)
+	if stream == nil {
+		return false
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		logs.Warn("marshal stream event failed, stream:", id, "error:", err)
+		return false
+	}
+
+	stream.publish(event, string(data), p.maxEvents)
+	return true
+}
+
+func (p *StreamHub) Finish(stream *eventStream, event, data string) {
+	stream.publish(event, data, p.maxEvents)
+	stream.finish(p.ttl)
+}
+
+func (p *StreamHub) Expire(now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+
+	for id, stream := range p.streams {
+		stream.Lock()
+		expired := (stream.done && now.After(stream.expiresAt)) || now.After(stream.deadline.Add(p.ttl))
+		stream.Unlock()
+
+		if expired {
+			delete(p.streams, id)
+		}
+	}
+}
+
+func streamProgressHandler(payload *spirit.Payload) (result interface{}, err error) {
+	if streamHub == nil {
+		return
+	}
+
+	if v, exist := payload.GetContext(CTX_STREAM_ID); exist {
+		if id, ok := v.(string); ok && id != "" {
+			streamHub.Publish(id, STREAM_EVENT_PROGRESS, payload.GetContent())
+		}
+	}
+
+	return
+}
+
+func writeStreamEvent(w http.ResponseWriter, event streamEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\n", event.Id, event.Event)
+	for _, line := range strings.Split(event.Data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+func serveEventStream(w http.ResponseWriter, r *http.Request, stream *eventStream, lastId int) {
+	flusher, _ := w.(http.Flusher)
+
+	writeAccessHeaders(w, r)
+	writeBasicHeaders(w, r)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set(STREAM_ID_HEADER, stream.id)
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(time.Duration(conf.HTTP.Stream.Heartbeat) * time.Second)
+	defer heartbeat.Stop()
+
+	deadline := time.NewTimer(time.Until(stream.deadline))
+	defer deadline.Stop()
+
+	for {
+		events, done, wait := stream.since(lastId)
+		for _, event := range events {
+			writeStreamEvent(w, event)
+			lastId = event.Id
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if done {
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-deadline.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func streamCallHandle(w http.ResponseWriter, r *http.Request) {
+	apiName := strings.TrimSpace(r.Header.Get(conf.HTTP.APIHeader))
+	if apiName == "" {
+		apiName = strings.TrimSpace(r.URL.Query().Get("api"))
+	}
+
+	if _, err := apiGraphProvider.Resolve(r, apiName); err != nil {
+		errorResponseHandler(err, w, r)
+		return
+	}
+
+	body, e := readRequestBody(r)
+	if e != nil {
+		errorResponseHandler(e, w, r)
+		return
+	}
+
+	timeout := effectiveTimeout(apiName, parseTimeoutHeader(r, API_CALL_TIMEOUT))
+
+	// the dispatcher answers a timed out call after its grace, and the result
+	// still has to be rendered and published before the stream is closed
+	stream, ok := streamHub.Open(timeout + SUB_CALL_TIMEOUT_GRACE + STREAM_RESULT_GRACE)
+	if !ok {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_STREAM_TOO_MANY.New(errors.Params{"max": conf.HTTP.Stream.MaxStreams})), w, r, http.StatusServiceUnavailable)
+		return
+	}
+
+	streamHub.Publish(stream.id, STREAM_EVENT_STREAM, map[string]string{"stream_id": stream.id, "api": apiName})
+
+	streamCtx := *apiRequestContext(r)
+	streamCtx.StreamId = stream.id
+	detached := detachRequest(r, &streamCtx)
+
+	finished := make(chan APIResponse, 1)
+
+	done := drainTracker.Track(apiName)
+	go func() {
+		defer done()
+
+		resp := apiDispatcher.Call(detached, APICall{
+			Name:    apiName,
+			API:     apiName,
+			Body:    body,
+			Timeout: timeout,
+		})
+		finished <- resp
+
+		opts := RenderOptions{Fields: requestFieldSelection(r)}
+		text, e := responseRenderer.RenderWithOptions(false, map[string]APIResponse{apiName: resp}, opts)
+		if e != nil {
+			data, _ := json.Marshal(newErrorAPIResponse(ERR_API_RESPONSE_REDNER_FAILED.New(errors.Params{"err": e})))
+			text = string(data)
+		}
+
+		streamHub.Finish(stream, STREAM_EVENT_RESULT, text)
+	}()
+
+	serveEventStream(w, r, stream, 0)
+
+	select {
+	case resp := <-finished:
+		apiRequestContext(r).Responses = map[string]APIResponse{apiName: resp}
+	default:
+	}
+}
+
+func streamResumeHandle(w http.ResponseWriter, r *http.Request) {
+	streamId := strings.TrimPrefix(r.URL.Path, conf.HTTP.PATH+conf.HTTP.Stream.Path+"/")
+
+	stream := streamHub.Get(streamId)
+	if stream == nil {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_STREAM_NOT_FOUND.New()), w, r, http.StatusNotFound)
+		return
+	}
+
+	lastId, _ := strconv.Atoi(strings.TrimSpace(r.Header.Get("Last-Event-ID")))
+
+	serveEventStream(w, r, stream, lastId)
+}
+
+func expireStreams() {
+	ticker := time.NewTicker(time.Duration(conf.HTTP.Stream.TTL) * time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		streamHub.Expire(now)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	API_EFFECTIVE_TIMEOUT_HEADER = "X-Api-Effective-Timeout"
+
+	DEFAULT_API_TIMEOUT = 30000
+)
+
+type TimeoutPolicy struct {
+	Default  time.Duration
+	Min      time.Duration
+	Max      time.Duration
+	AsyncMax time.Duration
+}
+
+func NewTimeoutPolicies(defaultTimeout, asyncTimeout int64, graphConf []GraphsConfig) (policies map[string]TimeoutPolicy, err error) {
+	policies = make(map[string]TimeoutPolicy)
+
+	for _, graph := range graphConf {
+		policy := TimeoutPolicy{
+			Default: time.Duration(graph.Timeout) * time.Millisecond,
+			Min:     time.Duration(graph.MinTimeout) * time.Millisecond,
+			Max:     time.Duration(graph.MaxTimeout) * time.Millisecond,
+		}
+
+		if policy.Max <= 0 && policy.Default <= 0 {
+			policy.Max = time.Duration(defaultTimeout) * time.Millisecond
+		} else if policy.Max <= 0 {
+			policy.Max = policy.Default
+		}
+
+		if policy.Default <= 0 {
+			policy.Default = time.Duration(defaultTimeout) * time.Millisecond
+			if policy.Default > policy.Max {
+				policy.Default = policy.Max
+			} else if policy.Default < policy.Min {
+				policy.Default = policy.Min
+			}
+		}
+
+		if policy.Min > policy.Max || policy.Default < policy.Min || policy.Default > policy.Max {
+			err = fmt.Errorf("bad timeout of api %s, the default timeout should be between min and max timeout", graph.API)
+			return
+		}
+
+		// async jobs are bounded on their own, so long jobs do not loosen the limit of sync calls
+		if policy.AsyncMax = time.Duration(graph.MaxAsyncTimeout) * time.Millisecond; policy.AsyncMax <= 0 {
+			policy.AsyncMax = time.Duration(asyncTimeout) * time.Millisecond
+			if policy.AsyncMax < policy.Max {
+				policy.AsyncMax = policy.Max
+			}
+		}
+
+		if policy.AsyncMax < policy.Min {
+			err = fmt.Errorf("bad timeout of api %s, the max async timeout should not be less than min timeout", graph.API)
+			return
+		}
+
+		policies[graph.API] = policy
+	}
+
+	return
+}
+
+func (p TimeoutPolicy) Effective(requested time.Duration) time.Duration {
+	switch {
+	case requested <= 0:
+		return p.Default
+	case requested < p.Min:
+		return p.Min
+	case requested > p.Max:
+		return p.Max
+	}
+	return requested
+}
+
+func (p TimeoutPolicy) EffectiveAsync(requested time.Duration) time.Duration {
+	switch {
+	case requested < p.Min:
+		return p.Min
+	case requested > p.AsyncMax:
+		return p.AsyncMax
+	}
+	return requested
+}
+
+func effectiveAsyncTimeout(apiName string, requested time.Duration) time.Duration {
+	if policy, exist := timeoutPolicies[apiName]; exist {
+		return policy.EffectiveAsync(requested)
+	}
+	return requested
+}
+
+func effectiveTimeout(apiName string, requested time.Duration) time.Duration {
+	if policy, exist := timeoutPolicies[apiName]; exist {
+		return policy.Effective(requested)
+	}
+	return requested
+}
+
+func applyTimeoutPolicy(w http.ResponseWriter, r *http.Request) {
+	timeout := effectiveTimeout(requestAPIName(r), parseTimeoutHeader(r, API_CALL_TIMEOUT))
+	if timeout <= 0 {
+		return
+	}
+
+	r.Header.Set(API_CALL_TIMEOUT, strconv.FormatInt(int64(timeout/time.Millisecond), 10))
+	writeEffectiveTimeout(w, timeout)
+}
+
+func writeEffectiveTimeout(w http.ResponseWriter, timeout time.Duration) {
+	if timeout > 0 {
+		w.Header().Set(API_EFFECTIVE_TIMEOUT_HEADER, strconv.FormatInt(int64(timeout/time.Millisecond), 10))
+	}
+}
+
+func longestCallTimeout(calls []APICall) (timeout time.Duration) {
+	for _, call := range calls {
+		if call.Timeout > timeout {
+			timeout = call.Timeout
+		}
+	}
+	return
+}
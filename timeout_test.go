@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewTimeoutPolicies(t *testing.T) {
+	tests := []struct {
+		graph  GraphsConfig
+		policy TimeoutPolicy
+		bad    bool
+	}{
+		{graph: GraphsConfig{API: "a"}, policy: TimeoutPolicy{Default: 30 * time.Second, Max: 30 * time.Second, AsyncMax: 10 * time.Minute}},
+		{graph: GraphsConfig{API: "a", Timeout: 5000}, policy: TimeoutPolicy{Default: 5 * time.Second, Max: 5 * time.Second, AsyncMax: 10 * time.Minute}},
+		{graph: GraphsConfig{API: "a", Timeout: 5000, MaxTimeout: 60000}, policy: TimeoutPolicy{Default: 5 * time.Second, Max: time.Minute, AsyncMax: 10 * time.Minute}},
+		{graph: GraphsConfig{API: "a", MaxTimeout: 10000}, policy: TimeoutPolicy{Default: 10 * time.Second, Max: 10 * time.Second, AsyncMax: 10 * time.Minute}},
+		{graph: GraphsConfig{API: "a", MaxTimeout: 60000}, policy: TimeoutPolicy{Default: 30 * time.Second, Max: time.Minute, AsyncMax: 10 * time.Minute}},
+		{graph: GraphsConfig{API: "a", MinTimeout: 40000, MaxTimeout: 60000}, policy: TimeoutPolicy{Default: 40 * time.Second, Min: 40 * time.Second, Max: time.Minute, AsyncMax: 10 * time.Minute}},
+		{graph: GraphsConfig{API: "a", MaxAsyncTimeout: 120000}, policy: TimeoutPolicy{Default: 30 * time.Second, Max: 30 * time.Second, AsyncMax: 2 * time.Minute}},
+		{graph: GraphsConfig{API: "a", MinTimeout: 40000}, bad: true},
+		{graph: GraphsConfig{API: "a", MinTimeout: 20000, MaxAsyncTimeout: 10000}, bad: true},
+		{graph: GraphsConfig{API: "a", Timeout: 5000, MinTimeout: 6000, MaxTimeout: 10000}, bad: true},
+		{graph: GraphsConfig{API: "a", Timeout: 20000, MaxTimeout: 10000}, bad: true},
+	}
+
+	for _, test := range tests {
+		policies, err := NewTimeoutPolicies(DEFAULT_API_TIMEOUT, DEFAULT_ASYNC_TIMEOUT, []GraphsConfig{test.graph})
+		if test.bad {
+			if err == nil {
+				t.Errorf("NewTimeoutPolicies(%+v) should fail", test.graph)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("NewTimeoutPolicies(%+v) failed: %s", test.graph, err)
+		} else if policy := policies[test.graph.API]; policy != test.policy {
+			t.Errorf("NewTimeoutPolicies(%+v) = %+v, want %+v", test.graph, policy, test.policy)
+		}
+	}
+}
+
+func TestTimeoutPolicyEffective(t *testing.T) {
+	policy := TimeoutPolicy{Default: 5 * time.Second, Min: time.Second, Max: 10 * time.Second}
+
+	tests := []struct {
+		requested time.Duration
+		effective time.Duration
+	}{
+		{requested: 0, effective: 5 * time.Second},
+		{requested: -time.Second, effective: 5 * time.Second},
+		{requested: 100 * time.Millisecond, effective: time.Second},
+		{requested: 3 * time.Second, effective: 3 * time.Second},
+		{requested: 10 * time.Second, effective: 10 * time.Second},
+		{requested: time.Minute, effective: 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		if effective := policy.Effective(test.requested); effective != test.effective {
+			t.Errorf("Effective(%s) = %s, want %s", test.requested, effective, test.effective)
+		}
+	}
+}
+
+func TestEffectiveAsyncTimeout(t *testing.T) {
+	defer func(policies map[string]TimeoutPolicy) {
+		timeoutPolicies = policies
+	}(timeoutPolicies)
+
+	var err error
+	timeoutPolicies, err = NewTimeoutPolicies(DEFAULT_API_TIMEOUT, DEFAULT_ASYNC_TIMEOUT, []GraphsConfig{
+		{API: "api.report.build"},
+		{API: "api.report.short", MinTimeout: 1000, MaxAsyncTimeout: 120000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asyncDefault := time.Duration(DEFAULT_ASYNC_TIMEOUT) * time.Millisecond
+
+	tests := []struct {
+		api       string
+		requested time.Duration
+		effective time.Duration
+	}{
+		{api: "api.report.build", requested: asyncDefault, effective: asyncDefault},
+		{api: "api.report.build", requested: 5 * time.Minute, effective: 5 * time.Minute},
+		{api: "api.report.build", requested: time.Hour, effective: asyncDefault},
+		{api: "api.report.short", requested: asyncDefault, effective: 2 * time.Minute},
+		{api: "api.report.short", requested: 100 * time.Millisecond, effective: time.Second},
+	}
+
+	for _, test := range tests {
+		if effective := effectiveAsyncTimeout(test.api, test.requested); effective != test.effective {
+			t.Errorf("effectiveAsyncTimeout(%q, %s) = %s, want %s", test.api, test.requested, effective, test.effective)
+		}
+	}
+
+	if sync := effectiveTimeout("api.report.build", time.Hour); sync != 30*time.Second {
+		t.Errorf("effectiveTimeout(%q, %s) = %s, want the sync limit %s", "api.report.build", time.Hour, sync, 30*time.Second)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gogap/logs"
+)
+
+const (
+	CLIENT_CERT_SUBJECT_HEADER = "X-Client-Cert-Subject"
+	TERMINATOR_SECRET_HEADER   = "X-Inlet-Terminator-Secret"
+	CTX_CLIENT_CERT_SUBJECT    = "client_cert_subject"
+
+	DEFAULT_TLS_MIN_VERSION = "1.2"
+	DEFAULT_TLS_CLIENT_AUTH = "require"
+
+	TLS_READ_HEADER_TIMEOUT = 10 * time.Second
+)
+
+var (
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+
+	tlsClientAuths = map[string]tls.ClientAuthType{
+		"require":  tls.RequireAndVerifyClientCert,
+		"optional": tls.VerifyClientCertIfGiven,
+	}
+)
+
+type TLSTerminator struct {
+	sync.RWMutex
+
+	tlsConf TLSConfig
+	config  *tls.Config
+	server  *http.Server
+
+	// generated per process, it tells the requests proxied by the terminator
+	// apart from anything else reaching the inlet over loopback
+	secret string
+}
+
+func NewTLSTerminator(tlsConf TLSConfig, inletAddress string) (terminator *TLSTerminator, err error) {
+	terminator = &TLSTerminator{tlsConf: tlsConf}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		err = fmt.Errorf("generate tls terminator secret failed, error: %s", err)
+		return
+	}
+	terminator.secret = hex.EncodeToString(secret)
+
+	if err = terminator.Reload(); err != nil {
+		return
+	}
+
+	var target *url.URL
+	if target, err = inletURL(inletAddress); err != nil {
+		return
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1
+
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		host := r.Host
+		director(r)
+
+		r.Header.Del("Forwarded")
+		r.Header.Del("X-Real-Ip")
+		r.Header.Del(CLIENT_CERT_SUBJECT_HEADER)
+		r.Header.Set(TERMINATOR_SECRET_HEADER, terminator.secret)
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("X-Forwarded-Host", host)
+		r.Host = host
+
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			r.Header.Set(CLIENT_CERT_SUBJECT_HEADER, r.TLS.VerifiedChains[0][0].Subject.String())
+		}
+	}
+
+	terminator.server = &http.Server{
+		Addr:              tlsConf.Address,
+		Handler:           proxy,
+		ReadHeaderTimeout: TLS_READ_HEADER_TIMEOUT,
+		TLSConfig: &tls.Config{
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				terminator.RLock()
+				defer terminator.RUnlock()
+
+				return terminator.config, nil
+			},
+		},
+	}
+
+	return
+}
+
+func inletURL(address string) (*url.URL, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("bad inlet address %s, error: %s", address, err)
+	}
+
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+
+	return url.Parse("http://" + net.JoinHostPort(host, port))
+}
+
+func (p *TLSTerminator) Reload() (err error) {
+	config := &tls.Config{}
+
+	var cert tls.Certificate
+	if cert, err = tls.LoadX509KeyPair(p.tlsConf.CertFile, p.tlsConf.KeyFile); err != nil {
+		return fmt.Errorf("load tls certificate failed, error: %s", err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+
+	var exist bool
+	if config.MinVersion, exist = tlsVersions[p.tlsConf.MinVersion]; !exist {
+		return fmt.Errorf("unknown tls min version: %s", p.tlsConf.MinVersion)
+	}
+
+	if len(p.tlsConf.Ciphers) > 0 {
+		if config.CipherSuites, err = parseCipherSuites(p.tlsConf.Ciphers); err != nil {
+			return
+		}
+	}
+
+	if p.tlsConf.ClientCAFile != "" {
+		var data []byte
+		if data, err = ioutil.ReadFile(p.tlsConf.ClientCAFile); err != nil {
+			return fmt.Errorf("read client ca failed, error: %s", err)
+		}
+
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificate found in client ca file: %s", p.tlsConf.ClientCAFile)
+		}
+
+		if config.ClientAuth, exist = tlsClientAuths[p.tlsConf.ClientAuth]; !exist {
+			return fmt.Errorf("unknown tls client auth: %s", p.tlsConf.ClientAuth)
+		}
+	}
+
+	p.Lock()
+	p.config = config
+	p.Unlock()
+
+	return
+}
+
+func parseCipherSuites(names []string) (suites []uint16, err error) {
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	for _, name := range names {
+		id, exist := known[strings.TrimSpace(name)]
+		if !exist {
+			return nil, fmt.Errorf("unknown or insecure tls cipher suite: %s", name)
+		}
+		suites = append(suites, id)
+	}
+
+	return
+}
+
+func (p *TLSTerminator) Run() {
+	drainTracker.OnDrain(func() {
+		go p.server.Shutdown(context.Background())
+	})
+
+	go p.reloadOnSignal()
+
+	logs.Info("tls terminator listening on", p.tlsConf.Address)
+
+	if err := p.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		logs.Error("tls terminator stopped, error:", err)
+	}
+}
+
+func (p *TLSTerminator) reloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		if err := p.Reload(); err != nil {
+			logs.Error("reload tls certificate failed, keeping the current one, error:", err)
+		} else {
+			logs.Info("tls certificate reloaded")
+		}
+	}
+}
+
+func clientCertSubject(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.String()
+	}
+
+	if !fromTerminator(r) {
+		return ""
+	}
+
+	return r.Header.Get(CLIENT_CERT_SUBJECT_HEADER)
+}
+
+func fromTerminator(r *http.Request) bool {
+	if tlsTerminator == nil || tlsTerminator.secret == "" {
+		return false
+	}
+
+	if ip := remoteIP(r); ip == nil || !ip.IsLoopback() {
+		return false
+	}
+
+	return tokenMatched(r.Header.Get(TERMINATOR_SECRET_HEADER), tlsTerminator.secret)
+}
+
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
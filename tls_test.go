@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIsLoopbackAddress(t *testing.T) {
+	tests := []struct {
+		address  string
+		loopback bool
+	}{
+		{address: "127.0.0.1:8080", loopback: true},
+		{address: "127.0.0.2:8080", loopback: true},
+		{address: "[::1]:8080", loopback: true},
+		{address: "localhost:8080", loopback: true},
+		{address: ":8080", loopback: false},
+		{address: "0.0.0.0:8080", loopback: false},
+		{address: "10.0.0.1:8080", loopback: false},
+		{address: "127.0.0.1", loopback: false},
+	}
+
+	for _, test := range tests {
+		if loopback := isLoopbackAddress(test.address); loopback != test.loopback {
+			t.Errorf("isLoopbackAddress(%q) = %v, want %v", test.address, loopback, test.loopback)
+		}
+	}
+}
+
+func TestFromTerminator(t *testing.T) {
+	defer func(terminator *TLSTerminator) {
+		tlsTerminator = terminator
+	}(tlsTerminator)
+
+	tests := []struct {
+		enabled    bool
+		remoteAddr string
+		secret     string
+		trusted    bool
+	}{
+		{enabled: true, remoteAddr: "127.0.0.1:1234", secret: "secret", trusted: true},
+		{enabled: true, remoteAddr: "127.0.0.1:1234", secret: "", trusted: false},
+		{enabled: true, remoteAddr: "127.0.0.1:1234", secret: "guess", trusted: false},
+		{enabled: true, remoteAddr: "10.0.0.1:1234", secret: "secret", trusted: false},
+		{enabled: false, remoteAddr: "127.0.0.1:1234", secret: "secret", trusted: false},
+	}
+
+	for _, test := range tests {
+		tlsTerminator = nil
+		if test.enabled {
+			tlsTerminator = &TLSTerminator{secret: "secret"}
+		}
+
+		r, _ := http.NewRequest(METHOD_POST, "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		r.Header.Set(CLIENT_CERT_SUBJECT_HEADER, "CN=client")
+		if test.secret != "" {
+			r.Header.Set(TERMINATOR_SECRET_HEADER, test.secret)
+		}
+
+		if trusted := fromTerminator(r); trusted != test.trusted {
+			t.Errorf("fromTerminator(%+v) = %v, want %v", test, trusted, test.trusted)
+		}
+
+		subject := ""
+		if test.trusted {
+			subject = "CN=client"
+		}
+		if s := clientCertSubject(r); s != subject {
+			t.Errorf("clientCertSubject(%+v) = %q, want %q", test, s, subject)
+		}
+	}
+}
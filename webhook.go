@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogap/logs"
+)
+
+const (
+	API_CALLBACK_URL_HEADER = "X-Api-Callback-Url"
+	WEBHOOK_JOB_HEADER      = "X-Api-Job-Id"
+	WEBHOOK_ATTEMPT_HEADER  = "X-Api-Webhook-Attempt"
+
+	DEFAULT_WEBHOOK_MAX_RETRIES = 5
+	DEFAULT_WEBHOOK_BACKOFF     = 1000
+	DEFAULT_WEBHOOK_MAX_BACKOFF = 60000
+	DEFAULT_WEBHOOK_TIMEOUT     = 10000
+	DEFAULT_WEBHOOK_DEAD_LETTER = "logs/webhook_dead_letter.log"
+)
+
+type WebhookAllowlist struct {
+	apis map[string][]originPattern
+}
+
+func NewWebhookAllowlist(httpConf HTTPConfig, graphConf []GraphsConfig) (allowlist *WebhookAllowlist, err error) {
+	allowlist = &WebhookAllowlist{apis: make(map[string][]originPattern)}
+
+	for _, graph := range graphConf {
+		if len(graph.WebhookAllowlist) > 0 && !httpConf.Signature.Enabled {
+			if !httpConf.Webhook.AllowUnsigned {
+				err = fmt.Errorf("webhooks of api %s would be sent unsigned, enable http.signature or set http.webhook.allow_unsigned", graph.API)
+				return
+			}
+			logs.Warn("http.signature is disabled, webhooks of api", graph.API, "are sent unsigned and receivers could not verify them")
+		}
+
+		for _, pattern := range graph.WebhookAllowlist {
+			var origin originPattern
+			if origin, err = parseOriginPattern(pattern); err != nil {
+				err = fmt.Errorf("bad webhook allowlist of api %s, error: %s", graph.API, err)
+				return
+			}
+			allowlist.apis[graph.API] = append(allowlist.apis[graph.API], origin)
+		}
+	}
+
+	return
+}
+
+func (p *WebhookAllowlist) Allowed(apiName, callback string) bool {
+	u, err := url.Parse(callback)
+	if err != nil || u.User != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	origin, err := ParseOrigin(u.Scheme + "://" + u.Host)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range p.apis[apiName] {
+		if pattern.Match(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type webhookDeadLetter struct {
+	Time      string          `json:"time"`
+	JobId     string          `json:"job_id"`
+	API       string          `json:"api"`
+	RequestId string          `json:"request_id"`
+	URL       string          `json:"url"`
+	Attempts  int             `json:"attempts"`
+	Error     string          `json:"error"`
+	Body      json.RawMessage `json:"body"`
+}
+
+type WebhookDeliverer struct {
+	client     *http.Client
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	deadLetter *rotateWriter
+}
+
+func NewWebhookDeliverer(webhookConf WebhookConfig) (deliverer *WebhookDeliverer, err error) {
+	deliverer = &WebhookDeliverer{
+		client: &http.Client{
+			Timeout: time.Duration(webhookConf.Timeout) * time.Millisecond,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxRetries: webhookConf.MaxRetries,
+		backoff:    time.Duration(webhookConf.Backoff) * time.Millisecond,
+		maxBackoff: time.Duration(webhookConf.MaxBackoff) * time.Millisecond,
+	}
+
+	deliverer.deadLetter, err = newRotateWriter(webhookConf.DeadLetter, int64(DEFAULT_ACCESS_LOG_MAX_SIZE)*1024*1024, DEFAULT_ACCESS_LOG_MAX_BACKUPS)
+
+	return
+}
+
+func (p *WebhookDeliverer) Deliver(job *AsyncJob) {
+	text, err := responseRenderer.Render(false, map[string]APIResponse{job.API: *job.Response})
+	if err != nil {
+		p.deadLettered(job, 0, err)
+		return
+	}
+
+	body := []byte(text)
+	backoff := p.backoff
+
+	for attempt := 1; ; attempt++ {
+		if err = p.post(job, body, attempt); err == nil {
+			return
+		}
+
+		logs.Warn("deliver webhook failed, job:", job.Id, "api:", job.API, "attempt:", attempt, "error:", err)
+
+		if attempt > p.maxRetries {
+			p.deadLettered(job, attempt, err)
+			return
+		}
+
+		time.Sleep(backoff)
+
+		if backoff *= 2; backoff > p.maxBackoff {
+			backoff = p.maxBackoff
+		}
+	}
+}
+
+func (p *WebhookDeliverer) post(job *AsyncJob, body []byte, attempt int) (err error) {
+	req, err := http.NewRequest(METHOD_POST, job.Callback, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(REQUEST_ID_HEADER, job.RequestId)
+	req.Header.Set(WEBHOOK_JOB_HEADER, job.Id)
+	req.Header.Set(WEBHOOK_ATTEMPT_HEADER, strconv.Itoa(attempt))
+
+	if signature, ok := signData(body); ok {
+		req.Header.Set(conf.HTTP.Signature.Header, signature)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return
+}
+
+func (p *WebhookDeliverer) deadLettered(job *AsyncJob, attempts int, err error) {
+	logs.Error("webhook dead lettered, job:", job.Id, "api:", job.API, "attempts:", attempts, "error:", err)
+
+	body := deadLetterBody(job)
+
+	line, e := json.Marshal(webhookDeadLetter{
+		Time:      time.Now().Format(time.RFC3339Nano),
+		JobId:     job.Id,
+		API:       job.API,
+		RequestId: job.RequestId,
+		URL:       job.Callback,
+		Attempts:  attempts,
+		Error:     err.Error(),
+		Body:      body,
+	})
+	if e != nil {
+		logs.Error(e)
+		return
+	}
+
+	if _, e = p.deadLetter.Write(append(line, '\n')); e != nil {
+		logs.Error(e)
+	}
+}
+
+// the redaction rules of an api are relative to its result, so the result is
+// redacted on a copy before the body is rendered again
+func deadLetterBody(job *AsyncJob) json.RawMessage {
+	resp := *job.Response
+
+	if resp.Result != nil {
+		data, e := json.Marshal(resp.Result)
+		if e != nil {
+			return json.RawMessage("null")
+		}
+
+		if resp.Result, e = redactor.RedactJSON(job.API, data); e != nil {
+			return json.RawMessage("null")
+		}
+	}
+
+	if text, e := responseRenderer.Render(false, map[string]APIResponse{job.API: resp}); e == nil && json.Valid([]byte(text)) {
+		return json.RawMessage(text)
+	}
+
+	data, _ := json.Marshal(resp)
+	return data
+}
+
+func requestCallbackURL(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(API_CALLBACK_URL_HEADER))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeadLetterBodyRedactsResult(t *testing.T) {
+	defer func(r *Redactor, renderer *APIResponseRenderer) {
+		redactor, responseRenderer = r, renderer
+	}(redactor, responseRenderer)
+
+	var err error
+	if redactor, err = NewRedactor(RedactConfig{}, []GraphsConfig{{API: "api.task.get", Redact: RedactConfig{Paths: []string{"owner.phone"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	responseRenderer = NewAPIResponseRenderer()
+
+	owner := map[string]interface{}{"name": "a", "phone": "13800000000"}
+	job := &AsyncJob{Id: "job", API: "api.task.get", Response: &APIResponse{Result: map[string]interface{}{"owner": owner}}}
+
+	body := string(deadLetterBody(job))
+	if strings.Contains(body, "13800000000") || !strings.Contains(body, REDACTED_VALUE) {
+		t.Errorf("deadLetterBody = %s, want the owner phone redacted", body)
+	}
+
+	if owner["phone"] != "13800000000" {
+		t.Errorf("deadLetterBody changed the job result, owner phone = %v", owner["phone"])
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogap/errors"
+	"github.com/gogap/logs"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DEFAULT_WEBSOCKET_PATH             = "/ws"
+	DEFAULT_WEBSOCKET_HEADER           = "X-Ws-Token"
+	DEFAULT_WEBSOCKET_MAX_INFLIGHT     = 16
+	DEFAULT_WEBSOCKET_MAX_PENDING      = 64
+	DEFAULT_WEBSOCKET_MAX_MESSAGE_SIZE = 64 * 1024
+	DEFAULT_WEBSOCKET_PING_INTERVAL    = 30
+	DEFAULT_WEBSOCKET_MAX_SESSIONS     = 1000
+
+	WEBSOCKET_WRITE_TIMEOUT = 10 * time.Second
+
+	WEBSOCKET_TOKEN_PROTOCOL_PREFIX = "token."
+)
+
+type wsRequestFrame struct {
+	Id     json.RawMessage `json:"id"`
+	API    string          `json:"api"`
+	Params interface{}     `json:"params"`
+	Fields string          `json:"fields"`
+}
+
+type wsResponseFrame struct {
+	Id       json.RawMessage `json:"id"`
+	API      string          `json:"api,omitempty"`
+	Response json.RawMessage `json:"response"`
+}
+
+const (
+	WEBSOCKET_SESSION = "websocket session"
+)
+
+var (
+	webSocketSessions int32
+	webSocketShutdown = make(chan struct{})
+)
+
+type wsSession struct {
+	conn *websocket.Conn
+	r    *http.Request
+
+	send     chan []byte
+	inflight chan struct{}
+	done     chan struct{}
+
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+}
+
+func webSocketHandle(w http.ResponseWriter, r *http.Request) {
+	r, _ = withAPIRequestContext(r)
+
+	// a query string token ends up in proxy and browser logs, so it is never accepted
+	token, protocol := webSocketToken(r)
+	if !tokenMatched(token, conf.HTTP.WebSocket.Token) {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_WEBSOCKET_UNAUTHORIZED.New()), w, r, http.StatusUnauthorized)
+		return
+	}
+
+	if clientIP := conf.HTTP.ClientIP(r); !apiGraphProvider.IsIPAllowed("", clientIP) {
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_API_IP_BLOCKED.New(errors.Params{"api": "", "ip": clientIP})), w, r, http.StatusForbidden)
+		return
+	}
+
+	if atomic.AddInt32(&webSocketSessions, 1) > conf.HTTP.WebSocket.MaxSessions {
+		atomic.AddInt32(&webSocketSessions, -1)
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_WEBSOCKET_TOO_MANY.New(errors.Params{"max": conf.HTTP.WebSocket.MaxSessions})), w, r, http.StatusServiceUnavailable)
+		return
+	}
+	defer atomic.AddInt32(&webSocketSessions, -1)
+
+	done, ok := drainTracker.Accept(WEBSOCKET_SESSION)
+	if !ok {
+		w.Header().Set("Connection", "close")
+		writeResponseWithStatusCode(newErrorAPIResponse(ERR_SERVER_SHUTTING_DOWN.New(errors.Params{"api": ""})), w, r, http.StatusServiceUnavailable)
+		return
+	}
+	defer done()
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || corsPolicies.Policy("").AllowOrigin(origin)
+		},
+	}
+
+	var responseHeader http.Header
+	if protocol != "" {
+		responseHeader = http.Header{"Sec-Websocket-Protocol": {protocol}}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		logs.Warn("websocket upgrade failed, request id:", requestId(r), "error:", err)
+		return
+	}
+
+	session := &wsSession{
+		conn:     conn,
+		r:        r,
+		send:     make(chan []byte, conf.HTTP.WebSocket.MaxPending),
+		inflight: make(chan struct{}, conf.HTTP.WebSocket.MaxInflight),
+		done:     make(chan struct{}),
+	}
+
+	session.serve()
+}
+
+// browsers could not set headers on a websocket, they offer the token as a
+// subprotocol instead, which has to be echoed back for the upgrade to succeed
+func webSocketToken(r *http.Request) (token, protocol string) {
+	if token = r.Header.Get(conf.HTTP.WebSocket.Header); token != "" {
+		return
+	}
+
+	for _, offered := range websocket.Subprotocols(r) {
+		if strings.HasPrefix(offered, WEBSOCKET_TOKEN_PROTOCOL_PREFIX) {
+			return strings.TrimPrefix(offered, WEBSOCKET_TOKEN_PROTOCOL_PREFIX), offered
+		}
+	}
+
+	return
+}
+
+func closeWebSocketSessions() {
+	close(webSocketShutdown)
+}
+
+func (p *wsSession) close(code int, reason string) {
+	p.closeOnce.Do(func() {
+		p.closeCode = code
+		p.closeReason = reason
+		close(p.done)
+	})
+}
+
+func (p *wsSession) serve() {
+	pingInterval := time.Duration(conf.HTTP.WebSocket.PingInterval) * time.Second
+	pongWait := pingInterval * 2
+
+	go p.writeLoop(pingInterval)
+	go p.closeOnShutdown()
+
+	p.conn.SetReadLimit(conf.HTTP.WebSocket.MaxMessageSize)
+	p.conn.SetReadDeadline(time.Now().Add(pongWait))
+	p.conn.SetPongHandler(func(string) error {
+		return p.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := p.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logs.Warn("websocket read failed, request id:", requestId(p.r), "error:", err)
+			}
+			p.close(websocket.CloseNormalClosure, "")
+			return
+		}
+
+		frame := wsRequestFrame{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if e := decoder.Decode(&frame); e != nil {
+			p.reply(nil, "", newErrorAPIResponse(ERR_WEBSOCKET_BAD_FRAME.New(errors.Params{"err": e})), nil)
+			continue
+		}
+
+		select {
+		case p.inflight <- struct{}{}:
+		case <-p.done:
+			return
+		}
+
+		go p.dispatch(frame)
+	}
+}
+
+func (p *wsSession) dispatch(frame wsRequestFrame) {
+	defer func() { <-p.inflight }()
+
+	done, ok := drainTracker.Accept(frame.API)
+	if !ok {
+		p.reply(frame.Id, frame.API, newErrorAPIResponse(ERR_SERVER_SHUTTING_DOWN.New(errors.Params{"api": frame.API})), nil)
+		return
+	}
+	defer done()
+
+	// a session only takes a global slot while one of its calls is running
+	if loadShedder != nil {
+		release, ok := loadShedder.AcquireGlobal()
+		if !ok {
+			logs.Warn("websocket call shed, api:", frame.API, "request id:", requestId(p.r))
+			recordShedStats(frame.API)
+			p.reply(frame.Id, frame.API, newErrorAPIResponse(ERR_API_OVERLOADED.New(errors.Params{"api": frame.API})), nil)
+			return
+		}
+		defer release()
+	}
+
+	if _, err := apiGraphProvider.Resolve(p.r, frame.API); err != nil {
+		p.reply(frame.Id, frame.API, newErrorAPIResponse(err), nil)
+		return
+	}
+
+	resp := apiDispatcher.Call(p.r, APICall{
+		Name:    frame.API,
+		API:     frame.API,
+		Content: frame.Params,
+		Timeout: effectiveTimeout(frame.API, parseTimeoutHeader(p.r, API_CALL_TIMEOUT)),
+	})
+
+	p.reply(frame.Id, frame.API, resp, ParseFieldSelection(frame.Fields))
+}
+
+func (p *wsSession) reply(id json.RawMessage, apiName string, resp APIResponse, fields *FieldSelection) {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	text, e := responseRenderer.RenderWithOptions(false, map[string]APIResponse{apiName: resp}, RenderOptions{Fields: fields})
+	if e != nil {
+		data, _ := json.Marshal(newErrorAPIResponse(ERR_API_RESPONSE_REDNER_FAILED.New(errors.Params{"err": e})))
+		text = string(data)
+	}
+
+	rendered := json.RawMessage(text)
+	if !json.Valid(rendered) {
+		rendered, _ = json.Marshal(text)
+	}
+
+	data, e := json.Marshal(wsResponseFrame{Id: id, API: apiName, Response: rendered})
+	if e != nil {
+		logs.Error(e)
+		return
+	}
+
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	select {
+	case p.send <- data:
+	default:
+		logs.Warn("websocket client is too slow, request id:", requestId(p.r))
+		p.close(websocket.CloseTryAgainLater, "too many pending responses")
+	}
+}
+
+func (p *wsSession) writeLoop(pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		p.conn.Close()
+	}()
+
+	for {
+		select {
+		case data := <-p.send:
+			p.conn.SetWriteDeadline(time.Now().Add(WEBSOCKET_WRITE_TIMEOUT))
+			if err := p.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				p.close(websocket.CloseNormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := p.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WEBSOCKET_WRITE_TIMEOUT)); err != nil {
+				p.close(websocket.CloseNormalClosure, "")
+				return
+			}
+		case <-p.done:
+			p.flush()
+			p.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(p.closeCode, p.closeReason), time.Now().Add(WEBSOCKET_WRITE_TIMEOUT))
+			return
+		}
+	}
+}
+
+// once the inlet drains, the session waits for its in-flight calls to answer
+// and then says goodbye
+func (p *wsSession) closeOnShutdown() {
+	select {
+	case <-webSocketShutdown:
+	case <-p.done:
+		return
+	}
+
+	for i := 0; i < cap(p.inflight); i++ {
+		select {
+		case p.inflight <- struct{}{}:
+		case <-p.done:
+			return
+		}
+	}
+
+	p.close(websocket.CloseGoingAway, "server shutting down")
+}
+
+func (p *wsSession) flush() {
+	for {
+		select {
+		case data := <-p.send:
+			p.conn.SetWriteDeadline(time.Now().Add(WEBSOCKET_WRITE_TIMEOUT))
+			if err := p.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestWebSocketToken(t *testing.T) {
+	defer func(httpConf HTTPConfig) {
+		conf.HTTP = httpConf
+	}(conf.HTTP)
+
+	conf.HTTP.WebSocket.Header = DEFAULT_WEBSOCKET_HEADER
+
+	tests := []struct {
+		header    string
+		protocols string
+		token     string
+		protocol  string
+	}{
+		{},
+		{header: "secret", token: "secret"},
+		{header: "secret", protocols: "token.other", token: "secret"},
+		{protocols: "token.secret", token: "secret", protocol: "token.secret"},
+		{protocols: "json, token.secret", token: "secret", protocol: "token.secret"},
+		{protocols: "json, secret"},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/v1/ws", nil)
+		if test.header != "" {
+			r.Header.Set(DEFAULT_WEBSOCKET_HEADER, test.header)
+		}
+		if test.protocols != "" {
+			r.Header.Set("Sec-Websocket-Protocol", test.protocols)
+		}
+
+		if token, protocol := webSocketToken(r); token != test.token || protocol != test.protocol {
+			t.Errorf("webSocketToken(%q, %q) = %q, %q, want %q, %q", test.header, test.protocols, token, protocol, test.token, test.protocol)
+		}
+	}
+}
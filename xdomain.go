@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEFAULT_XDOMAIN_PREFIX  = "/xdomain"
+	DEFAULT_XDOMAIN_MAX_AGE = 3600
+)
+
+type staticAsset struct {
+	Content     string
+	ContentType string
+	ETag        string
+	MaxAge      int
+}
+
+func newStaticAsset(content, contentType string, maxAge int) *staticAsset {
+	sum := sha1.Sum([]byte(content))
+	return &staticAsset{
+		Content:     content,
+		ContentType: contentType,
+		ETag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+		MaxAge:      maxAge,
+	}
+}
+
+func (p *staticAsset) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", p.ContentType)
+	w.Header().Set("ETag", p.ETag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(p.MaxAge))
+
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if tag = strings.TrimSpace(tag); tag == p.ETag || tag == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		w.Write([]byte(p.Content))
+	}
+}
+
+type XDomainAssets struct {
+	Proxy  *staticAsset
+	Script *staticAsset
+	Bridge *staticAsset
+}
+
+func xdomainOrigins(httpConf HTTPConfig) (patterns []string) {
+	patterns = append(patterns, httpConf.AllowOrigins...)
+	patterns = append(patterns, httpConf.CORS.AllowOrigins...)
+	return
+}
+
+func xdomainMasters(origins []string, path string) (masters map[string]string, err error) {
+	masters = make(map[string]string)
+
+	for _, origin := range origins {
+		if strings.TrimSpace(origin) == "*" {
+			masters["*"] = path
+			continue
+		}
+
+		var pattern originPattern
+		if pattern, err = parseOriginPattern(origin); err != nil {
+			return
+		}
+
+		host := pattern.Host
+		if pattern.Subdomain {
+			host = "*." + host
+		}
+
+		if pattern.Port != "" {
+			host += ":" + pattern.Port
+		}
+
+		if pattern.Scheme != "" {
+			masters[pattern.Scheme+"://"+host] = path
+			continue
+		}
+
+		masters["http://"+host] = path
+		masters["https://"+host] = path
+	}
+
+	return
+}
+
+func originPatternExpr(origin string) (expr string, err error) {
+	if strings.TrimSpace(origin) == "*" {
+		return "", fmt.Errorf("the * origin is not allowed for the post_message bridge, list the origins explicitly")
+	}
+
+	var pattern originPattern
+	if pattern, err = parseOriginPattern(origin); err != nil {
+		return
+	}
+
+	scheme := "https?"
+	if pattern.Scheme != "" {
+		scheme = regexp.QuoteMeta(pattern.Scheme)
+	}
+
+	host := regexp.QuoteMeta(pattern.Host)
+	if pattern.Subdomain {
+		host = `([a-z0-9_-]+\.)+` + host
+	}
+
+	port := ""
+	switch pattern.Port {
+	case "":
+	case "*":
+		port = `(:[0-9]+)?`
+	default:
+		port = ":" + pattern.Port
+	}
+
+	return "^" + scheme + "://" + host + port + "$", nil
+}
+
+func NewXDomainAssets(httpConf HTTPConfig) (assets *XDomainAssets, err error) {
+	origins := xdomainOrigins(httpConf)
+
+	masters, err := xdomainMasters(origins, httpConf.PATH)
+	if err != nil {
+		return
+	}
+
+	maxAge := httpConf.XDomain.MaxAge
+
+	assets = &XDomainAssets{
+		Proxy:  newStaticAsset(renderXDomainProxy(masters), "text/html; charset=utf-8", maxAge),
+		Script: newStaticAsset(xdomainProxyJS(), "application/javascript; charset=utf-8", maxAge),
+	}
+
+	if !httpConf.XDomain.PostMessage {
+		return
+	}
+
+	exprs := []string{}
+	for _, origin := range origins {
+		var expr string
+		if expr, err = originPatternExpr(origin); err != nil {
+			return
+		}
+		exprs = append(exprs, expr)
+	}
+
+	exposeHeaders := []string{REQUEST_ID_HEADER}
+	if httpConf.Signature.Enabled {
+		exposeHeaders = append(exposeHeaders, httpConf.Signature.Header)
+	}
+
+	jsonOrigins, _ := json.Marshal(exprs)
+	jsonEndpoint, _ := json.Marshal(httpConf.PATH)
+	jsonExpose, _ := json.Marshal(exposeHeaders)
+
+	bridge := strings.NewReplacer(
+		"{{#origins#}}", string(jsonOrigins),
+		"{{#endpoint#}}", string(jsonEndpoint),
+		"{{#expose#}}", string(jsonExpose),
+	).Replace(xdomainBridge())
+
+	assets.Bridge = newStaticAsset(bridge, "text/html; charset=utf-8", maxAge)
+
+	return
+}